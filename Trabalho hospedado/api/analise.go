@@ -0,0 +1,35 @@
+// api/analise.go
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/natsalete/Cavaleiros-do-Zodiaco-Algoritmo-A/game"
+)
+
+// AnaliseHandler devolve a análise de conectividade do jogo. Um cenário
+// rejeitado só por marcos inalcançáveis também é analisado, com status 200.
+func AnaliseHandler(w http.ResponseWriter, r *http.Request) {
+	game.EnableCORS(w)
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	var analise game.AnaliseConectividade
+	var conectividade game.ErroConectividade
+	g, err := game.JogoDaRequisicao(w, r)
+	switch {
+	case errors.As(err, &conectividade):
+		analise = conectividade.Analise
+	case err != nil:
+		game.ResponderErro(w, err)
+		return
+	default:
+		analise = g.Analisar()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(analise)
+}
@@ -0,0 +1,39 @@
+// api/atribuicao.go
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/natsalete/Cavaleiros-do-Zodiaco-Algoritmo-A/game"
+)
+
+// AtribuicaoHandler resolve apenas a escolha de equipes, sem busca de caminho.
+// Parâmetros: modo=exato|heuristico, limite_ms e semente (modo heurístico).
+func AtribuicaoHandler(w http.ResponseWriter, r *http.Request) {
+	game.EnableCORS(w)
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	g, err := game.JogoDaRequisicao(w, r)
+	if err != nil {
+		game.ResponderErro(w, err)
+		return
+	}
+
+	opcoes, err := game.OpcoesAtribuicaoDaRequisicao(r)
+	if err != nil {
+		game.ResponderErro(w, err)
+		return
+	}
+
+	atribuicao, err := game.ResolverAtribuicao(g.Cavaleiros, g.Casas, opcoes)
+	if err != nil {
+		game.ResponderErro(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(atribuicao)
+}
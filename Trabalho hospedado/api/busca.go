@@ -14,8 +14,19 @@ func BuscaHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	g := game.NovoJogo()
-	resultado := g.AStar()
+	g, err := game.JogoDaRequisicao(w, r)
+	if err != nil {
+		game.ResponderErro(w, err)
+		return
+	}
+
+	opcoes, err := game.OpcoesDaRequisicao(r)
+	if err != nil {
+		game.ResponderErro(w, err)
+		return
+	}
+
+	resultado := g.BuscarComContexto(r.Context(), opcoes)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resultado)
@@ -0,0 +1,34 @@
+// api/busca/progresso.go
+package busca
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/natsalete/Cavaleiros-do-Zodiaco-Algoritmo-A/game"
+)
+
+// ProgressoHandler aceita os mesmos parâmetros de /api/busca e responde com
+// Server-Sent Events: "progresso" periodicamente e "resultado" ao final.
+func ProgressoHandler(w http.ResponseWriter, r *http.Request) {
+	game.EnableCORS(w)
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	g, err := game.JogoDaRequisicao(w, r)
+	if err != nil {
+		game.ResponderErro(w, err)
+		return
+	}
+
+	opcoes, err := game.OpcoesDaRequisicao(r)
+	if err != nil {
+		game.ResponderErro(w, err)
+		return
+	}
+
+	if err := game.TransmitirProgresso(w, r, g, opcoes); err != nil {
+		log.Printf("progresso interrompido: %v", err)
+	}
+}
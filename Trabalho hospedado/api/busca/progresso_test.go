@@ -0,0 +1,129 @@
+package busca
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/natsalete/Cavaleiros-do-Zodiaco-Algoritmo-A/game"
+)
+
+type eventoSSE struct {
+	nome  string
+	dados string
+}
+
+func lerEventos(t *testing.T, resposta *http.Response) []eventoSSE {
+	t.Helper()
+	var eventos []eventoSSE
+	var atual eventoSSE
+	leitor := bufio.NewScanner(resposta.Body)
+	leitor.Buffer(nil, 1<<20)
+	for leitor.Scan() {
+		linha := leitor.Text()
+		switch {
+		case strings.HasPrefix(linha, "event: "):
+			atual.nome = strings.TrimPrefix(linha, "event: ")
+		case strings.HasPrefix(linha, "data: "):
+			atual.dados = strings.TrimPrefix(linha, "data: ")
+		case linha == "":
+			eventos = append(eventos, atual)
+			atual = eventoSSE{}
+		}
+	}
+	if err := leitor.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return eventos
+}
+
+func TestProgressoTerminaComResultado(t *testing.T) {
+	servidor := httptest.NewServer(http.HandlerFunc(ProgressoHandler))
+	defer servidor.Close()
+
+	resposta, err := http.Get(servidor.URL + "?modo=ordem_zodiacal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resposta.Body.Close()
+	if tipo := resposta.Header.Get("Content-Type"); tipo != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", tipo)
+	}
+
+	eventos := lerEventos(t, resposta)
+	if len(eventos) < 2 {
+		t.Fatalf("esperava progresso e resultado, recebidos %d eventos", len(eventos))
+	}
+
+	var progresso game.ProgressoBusca
+	penultimo := eventos[len(eventos)-2]
+	if penultimo.nome != "progresso" {
+		t.Fatalf("penúltimo evento = %q", penultimo.nome)
+	}
+	if err := json.Unmarshal([]byte(penultimo.dados), &progresso); err != nil {
+		t.Fatal(err)
+	}
+	if progresso.NosExpandidos == 0 || len(progresso.Casas) != 12 {
+		t.Errorf("progresso final = %+v", progresso)
+	}
+
+	var resultado game.ResultadoBusca
+	ultimo := eventos[len(eventos)-1]
+	if ultimo.nome != "resultado" {
+		t.Fatalf("último evento = %q", ultimo.nome)
+	}
+	if err := json.Unmarshal([]byte(ultimo.dados), &resultado); err != nil {
+		t.Fatal(err)
+	}
+	if !resultado.Sucesso || resultado.CustoTotal != 673 {
+		t.Errorf("resultado = sucesso %v, custo %d", resultado.Sucesso, resultado.CustoTotal)
+	}
+}
+
+// O held_karp não passa pelo motor do A*, mas relata os Dijkstras e a
+// programação dinâmica no mesmo rastro.
+func TestProgressoHeldKarp(t *testing.T) {
+	servidor := httptest.NewServer(http.HandlerFunc(ProgressoHandler))
+	defer servidor.Close()
+
+	for _, modo := range []string{game.MODO_LIVRE, game.MODO_ORDEM_ZODIACAL} {
+		resposta, err := http.Get(servidor.URL + "?algoritmo=held_karp&modo=" + modo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		eventos := lerEventos(t, resposta)
+		resposta.Body.Close()
+		if len(eventos) < 2 {
+			t.Fatalf("%s: esperava progresso e resultado, recebidos %d eventos", modo, len(eventos))
+		}
+
+		var progresso game.ProgressoBusca
+		var resultado game.ResultadoBusca
+		if err := json.Unmarshal([]byte(eventos[len(eventos)-2].dados), &progresso); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal([]byte(eventos[len(eventos)-1].dados), &resultado); err != nil {
+			t.Fatal(err)
+		}
+		if !resultado.Sucesso || progresso.NosExpandidos != resultado.Estatisticas.NosExpandidos || progresso.MelhorF == 0 || len(progresso.Casas) != 12 {
+			t.Errorf("%s: progresso final %+v, resultado com %d nós expandidos", modo, progresso, resultado.Estatisticas.NosExpandidos)
+		}
+	}
+}
+
+func TestProgressoRejeitaOpcoesInvalidas(t *testing.T) {
+	servidor := httptest.NewServer(http.HandlerFunc(ProgressoHandler))
+	defer servidor.Close()
+
+	resposta, err := http.Get(servidor.URL + "?algoritmo=nenhum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resposta.Body.Close()
+	if resposta.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d, esperado %d", resposta.StatusCode, http.StatusUnprocessableEntity)
+	}
+}
@@ -0,0 +1,36 @@
+// api/busca/trace.go
+package busca
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/natsalete/Cavaleiros-do-Zodiaco-Algoritmo-A/game"
+)
+
+// TraceHandler aceita os mesmos parâmetros de /api/busca e responde em NDJSON
+// comprimido: blocos {"passos": [...]} com cada expansão e, por último,
+// {"resultado": ...}. Sem max_nos, para após game.LIMITE_RASTRO_PADRAO nós.
+func TraceHandler(w http.ResponseWriter, r *http.Request) {
+	game.EnableCORS(w)
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	g, err := game.JogoDaRequisicao(w, r)
+	if err != nil {
+		game.ResponderErro(w, err)
+		return
+	}
+
+	opcoes, err := game.OpcoesDaRequisicao(r)
+	if err != nil {
+		game.ResponderErro(w, err)
+		return
+	}
+
+	// Depois do primeiro bloco o status já foi enviado: só resta registrar
+	if err := game.TransmitirRastro(w, r, g, opcoes); err != nil {
+		log.Printf("rastro interrompido: %v", err)
+	}
+}
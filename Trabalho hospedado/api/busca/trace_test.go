@@ -0,0 +1,59 @@
+package busca
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/natsalete/Cavaleiros-do-Zodiaco-Algoritmo-A/game"
+)
+
+// Sem max_nos o rastro do cenário padrão chega ao fim da busca, e as casas de
+// cada passo vêm como índices.
+func TestTraceCenarioPadraoCompleto(t *testing.T) {
+	servidor := httptest.NewServer(http.HandlerFunc(TraceHandler))
+	defer servidor.Close()
+
+	resposta, err := http.Get(servidor.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resposta.Body.Close()
+
+	var resultado *game.ResultadoBusca
+	passos, maisCasas := 0, 0
+	leitor := bufio.NewScanner(resposta.Body)
+	leitor.Buffer(nil, 1<<20)
+	for leitor.Scan() {
+		var bloco struct {
+			Passos []struct {
+				Casas []int `json:"casas"`
+			} `json:"passos"`
+			Resultado *game.ResultadoBusca `json:"resultado"`
+		}
+		if err := json.Unmarshal(leitor.Bytes(), &bloco); err != nil {
+			t.Fatal(err)
+		}
+		for _, passo := range bloco.Passos {
+			passos++
+			if len(passo.Casas) > maisCasas {
+				maisCasas = len(passo.Casas)
+			}
+		}
+		if bloco.Resultado != nil {
+			resultado = bloco.Resultado
+		}
+	}
+	if err := leitor.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if resultado == nil || !resultado.Sucesso || resultado.Abortada != "" {
+		t.Fatalf("resultado = %+v", resultado)
+	}
+	if passos != resultado.Estatisticas.NosExpandidos || maisCasas != 12 {
+		t.Errorf("%d passos (expandidos %d), no máximo %d casas por passo", passos, resultado.Estatisticas.NosExpandidos, maisCasas)
+	}
+}
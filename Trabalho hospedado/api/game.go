@@ -8,13 +8,26 @@ import (
 	"github.com/natsalete/Cavaleiros-do-Zodiaco-Algoritmo-A/game"
 )
 
+// GameHandler devolve o jogo em JSON ou, com ?formato=texto, no mapa em texto
 func GameHandler(w http.ResponseWriter, r *http.Request) {
 	game.EnableCORS(w)
 	if r.Method == "OPTIONS" {
 		return
 	}
 
-	g := game.NovoJogo()
+	g, err := game.JogoDaRequisicao(w, r)
+	if err != nil {
+		game.ResponderErro(w, err)
+		return
+	}
+
+	if r.URL.Query().Get("formato") == "texto" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if err := g.EscreverMapaTexto(w); err != nil {
+			game.ResponderErro(w, err)
+		}
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(g)
@@ -0,0 +1,40 @@
+// api/jogar.go
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/natsalete/Cavaleiros-do-Zodiaco-Algoritmo-A/game"
+)
+
+// JogarHandler recebe a partida inteira ({"jogo", "modo", "jogadas"}) por
+// POST, refaz as jogadas e devolve o estado atual; ao chegar ao Grande Mestre
+// com todas as casas conquistadas, inclui a comparação com o ótimo. Não guarda
+// sessão: a partida interativa, jogada a jogada, é a de /api/partida.
+func JogarHandler(w http.ResponseWriter, r *http.Request) {
+	game.EnableCORS(w)
+	if r.Method == "OPTIONS" {
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST, OPTIONS")
+		http.Error(w, "use POST com a partida no corpo", http.StatusMethodNotAllowed)
+		return
+	}
+
+	g, partida, err := game.LerPartida(http.MaxBytesReader(w, r.Body, game.TAMANHO_MAXIMO_CENARIO))
+	if err != nil {
+		game.ResponderErro(w, err)
+		return
+	}
+
+	estado, err := g.Jogar(partida.Modo, partida.Jogadas)
+	if err != nil {
+		game.ResponderErro(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(estado)
+}
@@ -0,0 +1,80 @@
+// api/partida.go
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/natsalete/Cavaleiros-do-Zodiaco-Algoritmo-A/game"
+)
+
+// As partidas vivem na memória desta função (ver game.SalaPartidas)
+var salaPartidas = game.NovaSalaPartidas()
+
+// PartidaHandler conduz a partida interativa:
+//
+//	POST /api/partida             {"jogo", "modo"} abre a partida e devolve o estado com o id
+//	POST /api/partida?id=         {"numero", "para", "cavaleiros"} aplica uma jogada
+//	GET  /api/partida?id=&desde=  long-poll: responde quando houver mais de desde jogadas
+func PartidaHandler(w http.ResponseWriter, r *http.Request) {
+	game.EnableCORS(w)
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	var estado game.EstadoPartida
+	var err error
+	switch {
+	case r.Method == http.MethodGet:
+		estado, err = aguardarPartida(r)
+		if r.Context().Err() != nil {
+			// O cliente desistiu da espera
+			return
+		}
+	case r.Method == http.MethodPost && r.URL.Query().Has("id"):
+		estado, err = jogarPartida(w, r)
+	case r.Method == http.MethodPost:
+		estado, err = abrirPartida(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, OPTIONS")
+		http.Error(w, "use POST para abrir a partida ou jogar e GET para aguardar o estado", http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		game.ResponderErro(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(estado)
+}
+
+func abrirPartida(w http.ResponseWriter, r *http.Request) (game.EstadoPartida, error) {
+	g, partida, err := game.LerPartida(http.MaxBytesReader(w, r.Body, game.TAMANHO_MAXIMO_CENARIO))
+	if err != nil {
+		return game.EstadoPartida{}, err
+	}
+	return salaPartidas.Abrir(g, partida.Modo, partida.Jogadas)
+}
+
+func jogarPartida(w http.ResponseWriter, r *http.Request) (game.EstadoPartida, error) {
+	jogada, err := game.LerJogadaNumerada(http.MaxBytesReader(w, r.Body, game.TAMANHO_MAXIMO_CENARIO))
+	if err != nil {
+		return game.EstadoPartida{}, err
+	}
+	return salaPartidas.Jogar(r.URL.Query().Get("id"), jogada)
+}
+
+func aguardarPartida(r *http.Request) (game.EstadoPartida, error) {
+	query := r.URL.Query()
+	desde := -1
+	if texto := query.Get("desde"); texto != "" {
+		valor, err := strconv.Atoi(texto)
+		if err != nil {
+			return game.EstadoPartida{}, game.ErrosValidacao{{Campo: "desde", Mensagem: "número inválido " + strconv.Quote(texto)}}
+		}
+		desde = valor
+	}
+	return salaPartidas.Aguardar(r.Context(), query.Get("id"), desde)
+}
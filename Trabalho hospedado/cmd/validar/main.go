@@ -0,0 +1,46 @@
+// cmd/validar
+//
+// Valida um cenário no formato do config.json e imprime os erros em JSON:
+//
+//	go run ./cmd/validar config.json
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/natsalete/Cavaleiros-do-Zodiaco-Algoritmo-A/game"
+)
+
+type relatorio struct {
+	Arquivo string              `json:"arquivo"`
+	Valido  bool                `json:"valido"`
+	Erros   game.ErrosValidacao `json:"erros,omitempty"`
+}
+
+func main() {
+	caminho := game.ARQUIVO_CENARIO
+	if len(os.Args) > 1 {
+		caminho = os.Args[1]
+	}
+
+	saida := relatorio{Arquivo: caminho, Valido: true}
+	if _, err := game.CarregarCenario(caminho); err != nil {
+		saida.Valido = false
+		if !errors.As(err, &saida.Erros) {
+			saida.Erros = game.ErrosValidacao{{Mensagem: err.Error()}}
+		}
+	}
+
+	codificador := json.NewEncoder(os.Stdout)
+	codificador.SetIndent("", "  ")
+	if err := codificador.Encode(saida); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if !saida.Valido {
+		os.Exit(1)
+	}
+}
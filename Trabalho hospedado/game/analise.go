@@ -0,0 +1,142 @@
+package game
+
+import (
+	"context"
+	"fmt"
+)
+
+// ---------------- Análise de conectividade ----------------
+// Rotula as componentes conexas das células transitáveis e, com um Dijkstra
+// a partir da Entrada, dá o menor custo de caminhada até cada marco. A
+// caminhada atravessa casas livremente: a análise não depende do modo.
+
+type AnaliseConectividade struct {
+	Alcancavel bool `json:"alcancavel"`
+	// Componentes conexas das células transitáveis, na ordem de leitura
+	Componentes       []ComponenteConexa `json:"componentes"`
+	ComponenteEntrada int                `json:"componente_entrada"`
+	Marcos            []AnaliseMarco     `json:"marcos"`
+	// Campos dos marcos que a Entrada não alcança
+	Inalcancaveis          []string `json:"inalcancaveis"`
+	CelulasAlcancaveis     int      `json:"celulas_alcancaveis"`
+	CelulasIntransponiveis int      `json:"celulas_intransponiveis"`
+}
+
+type ComponenteConexa struct {
+	Celulas int      `json:"celulas"`
+	Marcos  []string `json:"marcos"`
+}
+
+type AnaliseMarco struct {
+	Nome       string `json:"nome"`
+	Campo      string `json:"campo"`
+	Posicao    Point  `json:"posicao"`
+	Componente int    `json:"componente"`
+	Alcancavel bool   `json:"alcancavel"`
+	// Menor custo de caminhada desde a Entrada; -1 se inalcançável
+	CustoCaminhada int `json:"custo_caminhada"`
+}
+
+// ErroConectividade é o erro de Validar quando o jogo é bem formado, mas há
+// marcos inalcançáveis. Traz a análise que o detectou.
+type ErroConectividade struct {
+	Erros   ErrosValidacao
+	Analise AnaliseConectividade
+}
+
+func (e ErroConectividade) Error() string { return e.Erros.Error() }
+func (e ErroConectividade) Unwrap() error { return e.Erros }
+
+// Analisar supõe um jogo bem formado (mapa, terrenos e posições válidos).
+func (g *Game) Analisar() AnaliseConectividade {
+	analise := AnaliseConectividade{Inalcancaveis: []string{}}
+
+	componente := make([][]int, g.Size)
+	for x := range componente {
+		componente[x] = make([]int, g.Size)
+		for y := range componente[x] {
+			componente[x][y] = -1
+		}
+	}
+	for x := 0; x < g.Size; x++ {
+		for y := 0; y < g.Size; y++ {
+			origem := Point{x, y}
+			if !g.transitavel(origem) {
+				analise.CelulasIntransponiveis++
+				continue
+			}
+			if componente[x][y] >= 0 {
+				continue
+			}
+
+			id := len(analise.Componentes)
+			atual := ComponenteConexa{Marcos: []string{}}
+			componente[x][y] = id
+			fila := []Point{origem}
+			for len(fila) > 0 {
+				p := fila[0]
+				fila = fila[1:]
+				atual.Celulas++
+				for _, vizinho := range g.obterVizinhos(p) {
+					if componente[vizinho.X][vizinho.Y] < 0 {
+						componente[vizinho.X][vizinho.Y] = id
+						fila = append(fila, vizinho)
+					}
+				}
+			}
+			analise.Componentes = append(analise.Componentes, atual)
+		}
+	}
+
+	gr := g.prepararGrade()
+	var estatisticas Estatisticas
+	arvore, _ := g.caminhosMinimos(gr, g.Entrada, todasCasas, novoOrcamento(context.Background(), OpcoesBusca{}), &estatisticas)
+
+	analise.ComponenteEntrada = componente[g.Entrada.X][g.Entrada.Y]
+	analise.CelulasAlcancaveis = analise.Componentes[analise.ComponenteEntrada].Celulas
+	adicionar := func(nome, campo string, p Point) {
+		marco := AnaliseMarco{
+			Nome:           nome,
+			Campo:          campo,
+			Posicao:        p,
+			Componente:     componente[p.X][p.Y],
+			CustoCaminhada: -1,
+		}
+		if custo := arvore.custo[gr.celula(p)]; custo != SEM_CAMINHO {
+			marco.Alcancavel, marco.CustoCaminhada = true, custo
+		} else {
+			analise.Inalcancaveis = append(analise.Inalcancaveis, campo)
+		}
+		if marco.Componente >= 0 {
+			c := &analise.Componentes[marco.Componente]
+			c.Marcos = append(c.Marcos, nome)
+		}
+		analise.Marcos = append(analise.Marcos, marco)
+	}
+	adicionar("Entrada", "entrada", g.Entrada)
+	for i, casa := range g.Casas {
+		adicionar(casa.Nome, fmt.Sprintf("casas[%d].posicao", i), casa.Posicao)
+	}
+	adicionar("Grande Mestre", "grande_mestre", g.GrandeMestre)
+
+	analise.Alcancavel = len(analise.Inalcancaveis) == 0
+	return analise
+}
+
+// erro converte os marcos inalcançáveis em erros de validação, o grande
+// mestre antes das casas.
+func (a AnaliseConectividade) erro() error {
+	if a.Alcancavel {
+		return nil
+	}
+	var erros ErrosValidacao
+	if grandeMestre := a.Marcos[len(a.Marcos)-1]; !grandeMestre.Alcancavel {
+		erros.adicionar(grandeMestre.Campo, "inalcançável a partir da entrada")
+	}
+	for _, casa := range a.Marcos[1 : len(a.Marcos)-1] {
+		if !casa.Alcancavel {
+			erros.adicionar(casa.Campo, "casa %s inalcançável a partir da entrada", casa.Nome)
+		}
+	}
+	return ErroConectividade{Erros: erros, Analise: a}
+}
@@ -0,0 +1,574 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ---------------- Batalhas ----------------
+// Cada cavaleiro que luta em uma casa perde um ponto de energia; quem chega a
+// zero não luta mais e ao menos um cavaleiro precisa chegar vivo ao Grande
+// Mestre. Como o tempo de batalha não depende da ordem das casas, a melhor
+// escolha de equipes pode ser calculada antes e independente da busca. A busca
+// cobra os minutos inteiros de cada batalha (minutosBatalha), e é essa soma
+// que as atribuições minimizam.
+
+var ErrEnergiaInsuficiente = errors.New("energia dos cavaleiros insuficiente para vencer todas as casas")
+
+const (
+	ATRIBUICAO_EXATA      = "exato"
+	ATRIBUICAO_HEURISTICA = "heuristico"
+
+	LIMITE_ATRIBUICAO_PADRAO = 200 * time.Millisecond
+	// Perturbações seguidas sem melhora que encerram o modo heurístico antes
+	// do limite de tempo
+	PERTURBACOES_SEM_MELHORA = 1000
+)
+
+type Batalha struct {
+	CasaID     int      `json:"casa_id"`
+	Casa       string   `json:"casa"`
+	Cavaleiros []string `json:"cavaleiros"`
+	Tempo      float64  `json:"tempo"`
+
+	equipe []int
+}
+
+type EnergiaCavaleiro struct {
+	Nome    string `json:"nome"`
+	Energia int    `json:"energia"`
+}
+
+type OpcoesAtribuicao struct {
+	Modo        string
+	LimiteTempo time.Duration
+	Semente     int64
+}
+
+func (o OpcoesAtribuicao) Validar() error {
+	var erros ErrosValidacao
+	switch o.Modo {
+	case "", ATRIBUICAO_EXATA, ATRIBUICAO_HEURISTICA:
+	default:
+		erros.adicionar("modo", "modo de atribuição desconhecido %q (use %s ou %s)", o.Modo, ATRIBUICAO_EXATA, ATRIBUICAO_HEURISTICA)
+	}
+	if o.LimiteTempo < 0 {
+		erros.adicionar("limite_ms", "não pode ser negativo, recebido %d", o.LimiteTempo.Milliseconds())
+	}
+	if len(erros) > 0 {
+		return erros
+	}
+	return nil
+}
+
+// OpcoesAtribuicaoDaRequisicao lê modo, limite_ms e semente da URL.
+func OpcoesAtribuicaoDaRequisicao(r *http.Request) (OpcoesAtribuicao, error) {
+	query := r.URL.Query()
+	opcoes := OpcoesAtribuicao{Modo: query.Get("modo")}
+
+	var erros ErrosValidacao
+	if limite := query.Get("limite_ms"); limite != "" {
+		valor, err := strconv.Atoi(limite)
+		if err != nil {
+			erros.adicionar("limite_ms", "número inválido %q", limite)
+		}
+		opcoes.LimiteTempo = time.Duration(valor) * time.Millisecond
+	}
+	if semente := query.Get("semente"); semente != "" {
+		valor, err := strconv.ParseInt(semente, 10, 64)
+		if err != nil {
+			erros.adicionar("semente", "número inválido %q", semente)
+		}
+		opcoes.Semente = valor
+	}
+	if len(erros) > 0 {
+		return opcoes, fmt.Errorf("opções de atribuição: %w", erros)
+	}
+
+	if err := opcoes.Validar(); err != nil {
+		return opcoes, fmt.Errorf("opções de atribuição: %w", err)
+	}
+	return opcoes, nil
+}
+
+// Atribuicao é o cronograma de batalhas, indexado pela posição da casa em Casas
+type Atribuicao struct {
+	Modo            string             `json:"modo"`
+	Otima           bool               `json:"otima"`
+	Batalhas        []Batalha          `json:"batalhas"`
+	TempoTotal      float64            `json:"tempo_total"`
+	EnergiaRestante []EnergiaCavaleiro `json:"energia_restante"`
+}
+
+// ResolverAtribuicao escolhe que subconjunto de cavaleiros enfrenta cada casa
+// minimizando a soma dos minutos de batalha sob o orçamento de energia.
+func ResolverAtribuicao(cavaleiros []CavaleiroBronze, casas []CasaZodiaco, opcoes OpcoesAtribuicao) (Atribuicao, error) {
+	if len(cavaleiros) > 30 {
+		return Atribuicao{}, fmt.Errorf("no máximo 30 cavaleiros, recebidos %d", len(cavaleiros))
+	}
+
+	g := &Game{Cavaleiros: cavaleiros, Casas: casas}
+
+	var equipes []int
+	var err error
+	switch opcoes.Modo {
+	case "", ATRIBUICAO_EXATA:
+		opcoes.Modo = ATRIBUICAO_EXATA
+		equipes, err = g.atribuicaoExata()
+	case ATRIBUICAO_HEURISTICA:
+		if opcoes.LimiteTempo <= 0 {
+			opcoes.LimiteTempo = LIMITE_ATRIBUICAO_PADRAO
+		}
+		equipes, err = g.atribuicaoHeuristica(opcoes)
+	default:
+		return Atribuicao{}, fmt.Errorf("modo de atribuição desconhecido: %q", opcoes.Modo)
+	}
+	if err != nil {
+		return Atribuicao{}, err
+	}
+
+	return g.montarAtribuicao(opcoes.Modo, equipes), nil
+}
+
+// planejarBatalhas usa o modo exato e recorre ao heurístico quando o espaço
+// de estados de energia é grande demais.
+func (g *Game) planejarBatalhas() (Atribuicao, error) {
+	atribuicao, err := ResolverAtribuicao(g.Cavaleiros, g.Casas, OpcoesAtribuicao{Modo: ATRIBUICAO_EXATA})
+	if err != nil && !errors.Is(err, ErrEnergiaInsuficiente) {
+		return ResolverAtribuicao(g.Cavaleiros, g.Casas, OpcoesAtribuicao{Modo: ATRIBUICAO_HEURISTICA})
+	}
+	return atribuicao, err
+}
+
+func (g *Game) montarAtribuicao(modo string, equipes []int) Atribuicao {
+	atribuicao := Atribuicao{
+		Modo:            modo,
+		Otima:           modo == ATRIBUICAO_EXATA,
+		Batalhas:        make([]Batalha, len(g.Casas)),
+		EnergiaRestante: make([]EnergiaCavaleiro, len(g.Cavaleiros)),
+	}
+	for i, cavaleiro := range g.Cavaleiros {
+		atribuicao.EnergiaRestante[i] = EnergiaCavaleiro{Nome: cavaleiro.Nome, Energia: cavaleiro.Energia}
+	}
+
+	for casaID, mascara := range equipes {
+		batalha := Batalha{CasaID: casaID, Casa: g.Casas[casaID].Nome, Cavaleiros: []string{}}
+		for i := range g.Cavaleiros {
+			if mascara&(1<<i) != 0 {
+				batalha.equipe = append(batalha.equipe, i)
+				batalha.Cavaleiros = append(batalha.Cavaleiros, g.Cavaleiros[i].Nome)
+				atribuicao.EnergiaRestante[i].Energia--
+			}
+		}
+		batalha.Tempo = g.tempoBatalha(casaID, batalha.equipe)
+		atribuicao.Batalhas[casaID] = batalha
+		atribuicao.TempoTotal += float64(minutosBatalha(batalha.Tempo))
+	}
+	return atribuicao
+}
+
+func (g *Game) energiasIniciais() []int {
+	energias := make([]int, len(g.Cavaleiros))
+	for i, cavaleiro := range g.Cavaleiros {
+		if cavaleiro.Energia > 0 {
+			energias[i] = cavaleiro.Energia
+		}
+	}
+	return energias
+}
+
+// minutosBatalha é o que uma batalha custa no caminho: os minutos inteiros
+// do tempo, como a busca sempre cobrou.
+func minutosBatalha(tempo float64) int {
+	return int(tempo)
+}
+
+// tempoEquipe devolve os minutos cobrados, em float64 para que +Inf marque
+// uma equipe sem poder.
+func (g *Game) tempoEquipe(casaID, mascara int) float64 {
+	soma := 0.0
+	for i := range g.Cavaleiros {
+		if mascara&(1<<i) != 0 {
+			soma += g.Cavaleiros[i].PoderCosmico
+		}
+	}
+	if soma <= 0 {
+		return math.Inf(1)
+	}
+	return math.Trunc(float64(g.Casas[casaID].Dificuldade) / soma)
+}
+
+// ---------------- Modo exato: programação dinâmica sobre a energia ----------------
+const (
+	MAXIMO_CAVALEIROS_EXATO = 20
+	MAXIMO_ESTADOS_EXATO    = 1 << 24
+)
+
+type planejador struct {
+	g       *Game
+	bases   []int
+	maximos []int
+	estados int
+	memo    []float64
+	escolha []int32
+
+	// Por subconjunto de cavaleiros: soma do poder cósmico e energia gasta
+	poder   []float64
+	consumo []int
+}
+
+func (g *Game) atribuicaoExata() ([]int, error) {
+	if len(g.Cavaleiros) > MAXIMO_CAVALEIROS_EXATO {
+		return nil, fmt.Errorf("o modo exato aceita até %d cavaleiros, recebidos %d", MAXIMO_CAVALEIROS_EXATO, len(g.Cavaleiros))
+	}
+
+	p := &planejador{
+		g:       g,
+		bases:   make([]int, len(g.Cavaleiros)),
+		maximos: g.energiasIniciais(),
+	}
+
+	estado, base := 0, 1
+	for i, energia := range p.maximos {
+		p.bases[i] = base
+		estado += energia * base
+		base *= energia + 1
+		if base*(len(g.Casas)+1) > MAXIMO_ESTADOS_EXATO {
+			return nil, fmt.Errorf("estados de energia demais para o modo exato; use o modo %s", ATRIBUICAO_HEURISTICA)
+		}
+	}
+
+	// Memória indexada por casa e estado de energia; NaN marca "não calculado"
+	p.estados = base
+	p.memo = make([]float64, (len(g.Casas)+1)*base)
+	p.escolha = make([]int32, len(g.Casas)*base)
+	for i := range p.memo {
+		p.memo[i] = math.NaN()
+	}
+
+	p.poder = make([]float64, 1<<len(g.Cavaleiros))
+	p.consumo = make([]int, 1<<len(g.Cavaleiros))
+	for mascara := 1; mascara < len(p.poder); mascara++ {
+		i := bits.TrailingZeros(uint(mascara))
+		anterior := mascara &^ (1 << i)
+		p.poder[mascara] = p.poder[anterior] + g.Cavaleiros[i].PoderCosmico
+		p.consumo[mascara] = p.consumo[anterior] + p.bases[i]
+	}
+
+	if math.IsInf(p.melhor(0, estado), 1) {
+		return nil, ErrEnergiaInsuficiente
+	}
+
+	equipes := make([]int, len(g.Casas))
+	for casa := range g.Casas {
+		equipes[casa] = int(p.escolha[casa*p.estados+estado])
+		for i := range g.Cavaleiros {
+			if equipes[casa]&(1<<i) != 0 {
+				estado -= p.bases[i]
+			}
+		}
+	}
+	return equipes, nil
+}
+
+func (p *planejador) energia(estado, i int) int {
+	return estado / p.bases[i] % (p.maximos[i] + 1)
+}
+
+func (p *planejador) melhor(casa, estado int) float64 {
+	indice := casa*p.estados + estado
+	if valor := p.memo[indice]; !math.IsNaN(valor) {
+		return valor
+	}
+
+	// Cada casa restante consome ao menos um ponto e um ponto precisa sobrar
+	energiaTotal := 0
+	for i := range p.g.Cavaleiros {
+		energiaTotal += p.energia(estado, i)
+	}
+	if energiaTotal < len(p.g.Casas)-casa+1 {
+		p.memo[indice] = math.Inf(1)
+		return math.Inf(1)
+	}
+	if casa == len(p.g.Casas) {
+		p.memo[indice] = 0
+		return 0
+	}
+
+	disponiveis := 0
+	for i := range p.g.Cavaleiros {
+		if p.energia(estado, i) > 0 {
+			disponiveis |= 1 << i
+		}
+	}
+
+	melhorTempo, melhorMascara := math.Inf(1), 0
+	// Percorre todos os subconjuntos não vazios dos cavaleiros disponíveis
+	dificuldade := float64(p.g.Casas[casa].Dificuldade)
+	for mascara := disponiveis; mascara > 0; mascara = (mascara - 1) & disponiveis {
+		if p.poder[mascara] <= 0 {
+			continue
+		}
+
+		tempo := math.Trunc(dificuldade/p.poder[mascara]) + p.melhor(casa+1, estado-p.consumo[mascara])
+		if tempo < melhorTempo {
+			melhorTempo, melhorMascara = tempo, mascara
+		}
+	}
+
+	p.memo[indice] = melhorTempo
+	p.escolha[indice] = int32(melhorMascara)
+	return melhorTempo
+}
+
+// ---------------- Modo heurístico: guloso + busca local ----------------
+type solucaoHeuristica struct {
+	g       *Game
+	equipes []int
+	uso     []int
+	sobra   int
+}
+
+func (g *Game) atribuicaoHeuristica(opcoes OpcoesAtribuicao) ([]int, error) {
+	prazo := time.Now().Add(opcoes.LimiteTempo)
+	aleatorio := rand.New(rand.NewSource(opcoes.Semente))
+
+	s, err := g.atribuicaoGulosa()
+	if err != nil {
+		return nil, err
+	}
+	// Sem casas não há batalha a distribuir nem o que perturbar
+	if len(g.Casas) == 0 {
+		return s.equipes, nil
+	}
+	s.buscaLocal(prazo)
+
+	melhor := s.copiar()
+	melhorCusto := melhor.custo()
+
+	// Perturba a melhor solução e repete a busca local enquanto houver tempo
+	// e ela ainda melhorar de vez em quando
+	for semMelhora := 0; semMelhora < PERTURBACOES_SEM_MELHORA && time.Now().Before(prazo); semMelhora++ {
+		tentativa := melhor.copiar()
+		for k := 0; k < 3; k++ {
+			tentativa.perturbar(aleatorio)
+		}
+		tentativa.buscaLocal(prazo)
+
+		if custo := tentativa.custo(); custo < melhorCusto {
+			melhor, melhorCusto = tentativa, custo
+			semMelhora = -1
+		}
+	}
+	return melhor.equipes, nil
+}
+
+// atribuicaoGulosa garante um cavaleiro por casa e gasta a energia que sobra
+// onde ela mais reduz o tempo total.
+func (g *Game) atribuicaoGulosa() (*solucaoHeuristica, error) {
+	s := &solucaoHeuristica{
+		g:       g,
+		equipes: make([]int, len(g.Casas)),
+		uso:     make([]int, len(g.Cavaleiros)),
+	}
+	energias := g.energiasIniciais()
+	for _, energia := range energias {
+		s.sobra += energia
+	}
+	if s.sobra < len(g.Casas)+1 {
+		return nil, ErrEnergiaInsuficiente
+	}
+
+	// Casas mais difíceis primeiro, cada uma com o cavaleiro mais forte disponível
+	ordem := make([]int, len(g.Casas))
+	for i := range ordem {
+		ordem[i] = i
+	}
+	for i := 1; i < len(ordem); i++ {
+		for j := i; j > 0 && g.Casas[ordem[j]].Dificuldade > g.Casas[ordem[j-1]].Dificuldade; j-- {
+			ordem[j], ordem[j-1] = ordem[j-1], ordem[j]
+		}
+	}
+	for _, casa := range ordem {
+		escolhido := -1
+		for i := range g.Cavaleiros {
+			if s.uso[i] < energias[i] && (escolhido < 0 || g.Cavaleiros[i].PoderCosmico > g.Cavaleiros[escolhido].PoderCosmico) {
+				escolhido = i
+			}
+		}
+		s.equipes[casa] = 1 << escolhido
+		s.uso[escolhido]++
+		s.sobra--
+	}
+
+	for s.sobra > 1 {
+		melhorGanho, melhorCasa, melhorCavaleiro := 0.0, -1, -1
+		for casa := range g.Casas {
+			atual := g.tempoEquipe(casa, s.equipes[casa])
+			for i := range g.Cavaleiros {
+				if s.equipes[casa]&(1<<i) != 0 || s.uso[i] >= energias[i] {
+					continue
+				}
+				if ganho := atual - g.tempoEquipe(casa, s.equipes[casa]|1<<i); ganho > melhorGanho {
+					melhorGanho, melhorCasa, melhorCavaleiro = ganho, casa, i
+				}
+			}
+		}
+		if melhorCasa < 0 {
+			break
+		}
+		s.equipes[melhorCasa] |= 1 << melhorCavaleiro
+		s.uso[melhorCavaleiro]++
+		s.sobra--
+	}
+	return s, nil
+}
+
+func (s *solucaoHeuristica) custo() float64 {
+	total := 0.0
+	for casa, mascara := range s.equipes {
+		total += s.g.tempoEquipe(casa, mascara)
+	}
+	return total
+}
+
+func (s *solucaoHeuristica) copiar() *solucaoHeuristica {
+	c := *s
+	c.equipes = append([]int(nil), s.equipes...)
+	c.uso = append([]int(nil), s.uso...)
+	return &c
+}
+
+func (s *solucaoHeuristica) podeEntrar(casa, i int) bool {
+	return s.equipes[casa]&(1<<i) == 0 && s.uso[i] < s.g.Cavaleiros[i].Energia && s.sobra > 1
+}
+
+func (s *solucaoHeuristica) podeSair(casa, i int) bool {
+	return s.equipes[casa]&(1<<i) != 0 && bits.OnesCount(uint(s.equipes[casa])) > 1
+}
+
+func (s *solucaoHeuristica) alternar(casa, i int) {
+	if s.equipes[casa]&(1<<i) != 0 {
+		s.uso[i]--
+		s.sobra++
+	} else {
+		s.uso[i]++
+		s.sobra--
+	}
+	s.equipes[casa] ^= 1 << i
+}
+
+// buscaLocal aplica a primeira melhoria encontrada entre incluir, retirar ou
+// transferir um cavaleiro até chegar a um ótimo local.
+func (s *solucaoHeuristica) buscaLocal(prazo time.Time) {
+	for melhorou := true; melhorou && time.Now().Before(prazo); {
+		melhorou = false
+		for casa := range s.equipes {
+			for i := range s.g.Cavaleiros {
+				antes := s.custo()
+
+				if s.podeEntrar(casa, i) {
+					s.alternar(casa, i)
+					if s.custo() < antes {
+						melhorou = true
+						continue
+					}
+					s.alternar(casa, i)
+				}
+
+				if !s.podeSair(casa, i) {
+					continue
+				}
+				s.alternar(casa, i)
+				if s.custo() < antes {
+					melhorou = true
+					continue
+				}
+				transferiu := false
+				for destino := range s.equipes {
+					if destino == casa || !s.podeEntrar(destino, i) {
+						continue
+					}
+					s.alternar(destino, i)
+					if s.custo() < antes {
+						transferiu = true
+						break
+					}
+					s.alternar(destino, i)
+				}
+				if transferiu {
+					melhorou = true
+				} else {
+					s.alternar(casa, i)
+				}
+			}
+		}
+	}
+}
+
+func (s *solucaoHeuristica) perturbar(aleatorio *rand.Rand) {
+	casa := aleatorio.Intn(len(s.equipes))
+	i := aleatorio.Intn(len(s.g.Cavaleiros))
+	if s.podeEntrar(casa, i) || s.podeSair(casa, i) {
+		s.alternar(casa, i)
+	}
+}
+
+// ---------------- Cronograma ao longo do caminho ----------------
+// EtapaCasa descreve a conquista de uma casa: o passo do caminho em que ela foi
+// alcançada, o instante de chegada (em minutos desde a entrada), a batalha e a
+// caminhada acumulada desde a casa anterior.
+type EtapaCasa struct {
+	Batalha
+	Passo          int `json:"passo"`
+	Chegada        int `json:"chegada"`
+	Saida          int `json:"saida"`
+	CustoCaminhada int `json:"custo_caminhada"`
+}
+
+// cronograma percorre o caminho cobrando os mesmos custos da busca e devolve
+// as etapas, o custo de caminhada e o custo de batalhas.
+func (g *Game) cronograma(caminho []Point, atribuicao Atribuicao) ([]EtapaCasa, int, int) {
+	casaEm := make(map[Point]int, len(g.Casas))
+	for i, casa := range g.Casas {
+		casaEm[casa.Posicao] = i
+	}
+
+	var etapas []EtapaCasa
+	conquistada := make([]bool, len(g.Casas))
+	relogio, caminhada, batalhas, ultimaSaida := 0, 0, 0, 0
+	for passo, p := range caminho {
+		if passo > 0 {
+			custo := g.custoPasso(caminho[passo-1], p)
+			relogio += custo
+			caminhada += custo
+		}
+
+		casaID, existe := casaEm[p]
+		if !existe || conquistada[casaID] {
+			continue
+		}
+		conquistada[casaID] = true
+
+		batalha := atribuicao.Batalhas[casaID]
+		etapa := EtapaCasa{
+			Batalha:        batalha,
+			Passo:          passo,
+			Chegada:        relogio,
+			CustoCaminhada: relogio - ultimaSaida,
+		}
+		relogio += minutosBatalha(batalha.Tempo)
+		batalhas += minutosBatalha(batalha.Tempo)
+		etapa.Saida = relogio
+		ultimaSaida = relogio
+
+		etapas = append(etapas, etapa)
+	}
+	return etapas, caminhada, batalhas
+}
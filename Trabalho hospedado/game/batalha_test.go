@@ -0,0 +1,71 @@
+package game
+
+import "testing"
+
+// O modo exato minimiza os minutos que a busca cobra (o tempo de cada batalha
+// truncado), não a soma dos tempos fracionários: confere contra a força bruta
+// em todas as equipes possíveis.
+func TestAtribuicaoExataMinimizaMinutosCobrados(t *testing.T) {
+	g := NovoJogo()
+	cavaleiros := append([]CavaleiroBronze(nil), g.Cavaleiros...)
+	for i := range cavaleiros {
+		cavaleiros[i].Energia = 2
+	}
+	casas := g.Casas[8:]
+
+	atribuicao, err := ResolverAtribuicao(cavaleiros, casas, OpcoesAtribuicao{Modo: ATRIBUICAO_EXATA})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cobrado := 0
+	for _, batalha := range atribuicao.Batalhas {
+		cobrado += minutosBatalha(batalha.Tempo)
+	}
+	if float64(cobrado) != atribuicao.TempoTotal {
+		t.Errorf("tempo_total %g, batalhas cobram %d", atribuicao.TempoTotal, cobrado)
+	}
+
+	jogo := &Game{Cavaleiros: cavaleiros, Casas: casas}
+	uso := make([]int, len(cavaleiros))
+	melhor := -1
+	var percorrer func(casa, total int)
+	percorrer = func(casa, total int) {
+		if casa == len(casas) {
+			sobra := 0
+			for i, cavaleiro := range cavaleiros {
+				sobra += cavaleiro.Energia - uso[i]
+			}
+			if sobra > 0 && (melhor < 0 || total < melhor) {
+				melhor = total
+			}
+			return
+		}
+		for mascara := 1; mascara < 1<<len(cavaleiros); mascara++ {
+			var equipe []int
+			for i := range cavaleiros {
+				if mascara&(1<<i) != 0 {
+					equipe = append(equipe, i)
+				}
+			}
+			valida := true
+			for _, i := range equipe {
+				valida = valida && uso[i] < cavaleiros[i].Energia
+			}
+			if !valida {
+				continue
+			}
+			for _, i := range equipe {
+				uso[i]++
+			}
+			percorrer(casa+1, total+minutosBatalha(jogo.tempoBatalha(casa, equipe)))
+			for _, i := range equipe {
+				uso[i]--
+			}
+		}
+	}
+	percorrer(0, 0)
+
+	if cobrado != melhor {
+		t.Errorf("modo exato cobra %d minutos, a força bruta acha %d", cobrado, melhor)
+	}
+}
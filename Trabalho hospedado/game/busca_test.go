@@ -0,0 +1,239 @@
+package game
+
+import (
+	"container/heap"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func benchmarkBusca(b *testing.B, g *Game, opcoes OpcoesBusca) {
+	b.ReportAllocs()
+	expandidos := 0
+	inicio := time.Now()
+	for i := 0; i < b.N; i++ {
+		r := g.Buscar(opcoes)
+		if !r.Sucesso {
+			b.Fatalf("busca falhou: %s", r.Motivo)
+		}
+		expandidos += r.Estatisticas.NosExpandidos
+	}
+	b.ReportMetric(float64(expandidos)/float64(b.N), "nos/op")
+	b.ReportMetric(float64(expandidos)/time.Since(inicio).Seconds(), "nos/s")
+}
+
+func BenchmarkBuscaLivre(b *testing.B) {
+	benchmarkBusca(b, NovoJogo(), OpcoesBusca{Modo: MODO_LIVRE})
+}
+
+func BenchmarkBuscaOrdemZodiacal(b *testing.B) {
+	benchmarkBusca(b, NovoJogo(), OpcoesBusca{Modo: MODO_ORDEM_ZODIACAL})
+}
+
+// ---------------- Linha de base: chaves de texto ----------------
+// buscaChaveTexto é a busca de antes da máscara compactada, mantida só para
+// comparar nos benchmarks: cada nó copia um []bool das casas e o conjunto
+// fechado é um mapa de chaves fmt.Sprintf. Usa a heurística clássica, como o
+// padrão de Buscar.
+type noTexto struct {
+	Point
+	g, f      int
+	visitadas []bool
+	proxima   int
+}
+
+type filaTexto []*noTexto
+
+func (f filaTexto) Len() int            { return len(f) }
+func (f filaTexto) Less(i, j int) bool  { return f[i].f < f[j].f }
+func (f filaTexto) Swap(i, j int)       { f[i], f[j] = f[j], f[i] }
+func (f *filaTexto) Push(x interface{}) { *f = append(*f, x.(*noTexto)) }
+func (f *filaTexto) Pop() interface{} {
+	antiga := *f
+	no := antiga[len(antiga)-1]
+	*f = antiga[:len(antiga)-1]
+	return no
+}
+
+func buscaChaveTexto(g *Game, ordenado bool) (custo, expandidos int) {
+	plano, err := g.planejarBatalhas()
+	if err != nil {
+		return -1, 0
+	}
+	casaEm := make(map[Point]int, len(g.Casas))
+	for i, casa := range g.Casas {
+		casaEm[casa.Posicao] = i
+	}
+	restantes := func(no *noTexto) int {
+		if ordenado {
+			return len(g.Casas) - no.proxima
+		}
+		n := 0
+		for _, visitada := range no.visitadas {
+			if !visitada {
+				n++
+			}
+		}
+		return n
+	}
+
+	fila := &filaTexto{}
+	inicial := &noTexto{Point: g.Entrada, f: distanciaManhattan(g.Entrada, g.GrandeMestre) + len(g.Casas)*50}
+	if !ordenado {
+		inicial.visitadas = make([]bool, len(g.Casas))
+	}
+	heap.Push(fila, inicial)
+	fechados := make(map[string]int)
+
+	for fila.Len() > 0 {
+		atual := heap.Pop(fila).(*noTexto)
+		chave := fmt.Sprintf("%d,%d,%v", atual.X, atual.Y, atual.visitadas)
+		if ordenado {
+			chave = fmt.Sprintf("%d,%d,%d", atual.X, atual.Y, atual.proxima)
+		}
+		if melhorG, existe := fechados[chave]; existe && melhorG <= atual.g {
+			continue
+		}
+		fechados[chave] = atual.g
+		expandidos++
+
+		if atual.Point == g.GrandeMestre && restantes(atual) == 0 {
+			return atual.g, expandidos
+		}
+
+		for _, vizinho := range g.obterVizinhos(atual.Point) {
+			novo := &noTexto{Point: vizinho, g: atual.g + g.custoPasso(atual.Point, vizinho), proxima: atual.proxima}
+			if !ordenado {
+				novo.visitadas = append([]bool(nil), atual.visitadas...)
+			}
+			if casaID, existe := casaEm[vizinho]; existe {
+				switch {
+				case ordenado && casaID > novo.proxima:
+					continue
+				case ordenado && casaID == novo.proxima:
+					novo.g += minutosBatalha(plano.Batalhas[casaID].Tempo)
+					novo.proxima++
+				case !ordenado && !novo.visitadas[casaID]:
+					novo.g += minutosBatalha(plano.Batalhas[casaID].Tempo)
+					novo.visitadas[casaID] = true
+				}
+			}
+			novo.f = novo.g + distanciaManhattan(vizinho, g.GrandeMestre) + restantes(novo)*50
+			heap.Push(fila, novo)
+		}
+	}
+	return -1, expandidos
+}
+
+// Antes (chaves de texto) e depois (máscara compactada) no mesmo jogo; a
+// métrica nos/s é a comparação pedida.
+func BenchmarkEstadoCompactado(b *testing.B) {
+	g := NovoJogo()
+	for _, modo := range []string{MODO_ORDEM_ZODIACAL, MODO_LIVRE} {
+		ordenado := modo == MODO_ORDEM_ZODIACAL
+		b.Run(modo+"/chave_texto", func(b *testing.B) {
+			b.ReportAllocs()
+			expandidos := 0
+			inicio := time.Now()
+			for i := 0; i < b.N; i++ {
+				custo, n := buscaChaveTexto(g, ordenado)
+				if custo < 0 {
+					b.Fatal("busca de linha de base falhou")
+				}
+				expandidos += n
+			}
+			b.ReportMetric(float64(expandidos)/float64(b.N), "nos/op")
+			b.ReportMetric(float64(expandidos)/time.Since(inicio).Seconds(), "nos/s")
+		})
+		b.Run(modo+"/mascara", func(b *testing.B) {
+			benchmarkBusca(b, g, OpcoesBusca{Modo: modo})
+		})
+	}
+}
+
+// Cenário com o máximo de casas suportado pela máscara de 64 bits
+func BenchmarkBuscaSessentaEQuatroCasas(b *testing.B) {
+	g := &Game{Size: 42, Cavaleiros: NovoJogo().Cavaleiros, Entrada: Point{0, 0}, GrandeMestre: Point{41, 41}}
+	for i := range g.Cavaleiros {
+		g.Cavaleiros[i].Energia = 20
+	}
+	for i := 0; i < MAXIMO_CASAS; i++ {
+		g.Casas = append(g.Casas, CasaZodiaco{fmt.Sprintf("Casa %d", i+1), 50, Point{2 + i/8*5, 2 + i%8*5}})
+	}
+	g.inicializarMapa()
+
+	benchmarkBusca(b, g, OpcoesBusca{Modo: MODO_ORDEM_ZODIACAL})
+}
+
+// santuarioUniforme gera um santuário só de PLANO entre as montanhas, que
+// viram muralhas intransponíveis quando pedido.
+func santuarioUniforme(tb testing.TB, semente int64, tamanho, casas int, muralhas bool) *Game {
+	g, err := GerarSantuario(ParametrosGeracao{Semente: semente, Tamanho: tamanho, Casas: casas, Densidade: 0.45, Sinuosidade: 0.35})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	for x := range g.Mapa {
+		for y := range g.Mapa[x] {
+			if g.Mapa[x][y] == ROCHOSO {
+				g.Mapa[x][y] = PLANO
+			}
+		}
+	}
+	g.Terrenos[MONTANHOSO].Intransponivel = muralhas
+	if err := g.normalizar(); err != nil {
+		tb.Fatal(err)
+	}
+	return g
+}
+
+// Nos movimentos diagonal e hexagonal não há saltos, mas o JPS deve cair no
+// A* sem mudar o custo.
+func TestSaltosMesmoCustoQueAEstrela(t *testing.T) {
+	for _, tipo := range []string{MOVIMENTO_ORTOGONAL, MOVIMENTO_DIAGONAL, MOVIMENTO_HEXAGONAL} {
+		for semente := int64(0); semente < 12; semente++ {
+			g := santuarioUniforme(t, semente, 19+int(semente)*3, 1+int(semente)%5, semente%2 == 0)
+			g.Movimento = Movimento{Tipo: tipo}
+			if err := g.normalizar(); err != nil {
+				t.Fatal(err)
+			}
+			for _, modo := range []string{MODO_LIVRE, MODO_ORDEM_ZODIACAL} {
+				aEstrela := g.Buscar(OpcoesBusca{Modo: modo, Heuristica: HEURISTICA_MST_BATALHAS})
+				dijkstra := g.Buscar(OpcoesBusca{Modo: modo, Algoritmo: ALGORITMO_DIJKSTRA})
+				saltos := g.Buscar(OpcoesBusca{Modo: modo, Heuristica: HEURISTICA_MST_BATALHAS, Algoritmo: ALGORITMO_JPS})
+				if !saltos.Sucesso || saltos.CustoTotal != aEstrela.CustoTotal || saltos.CustoTotal != dijkstra.CustoTotal {
+					t.Errorf("%s, semente %d, %s: jps custou %d, A* %d, dijkstra %d", tipo, semente, modo, saltos.CustoTotal, aEstrela.CustoTotal, dijkstra.CustoTotal)
+				}
+				for i := 1; i < len(saltos.Caminho); i++ {
+					if g.Movimento.passos(saltos.Caminho[i-1], saltos.Caminho[i]) != 1 {
+						t.Fatalf("%s, semente %d, %s: caminho pula de %v para %v", tipo, semente, modo, saltos.Caminho[i-1], saltos.Caminho[i])
+					}
+				}
+			}
+		}
+	}
+}
+
+// A* e JPS nos mesmos santuários grandes. Com muralhas as regiões uniformes
+// só têm fronteira nas casas; cercadas de montanhas, toda a borda é fronteira
+// e o JPS volta a expandir quase como o A*.
+func BenchmarkSaltos(b *testing.B) {
+	cenarios := []struct {
+		nome     string
+		tamanho  int
+		casas    int
+		muralhas bool
+		modo     string
+	}{
+		{"muralhas_200_livre", 200, 4, true, MODO_LIVRE},
+		{"muralhas_256_ordem", 256, 12, true, MODO_ORDEM_ZODIACAL},
+		{"montanhas_256_ordem", 256, 12, false, MODO_ORDEM_ZODIACAL},
+	}
+	for _, cenario := range cenarios {
+		g := santuarioUniforme(b, 42, cenario.tamanho, cenario.casas, cenario.muralhas)
+		for _, algoritmo := range []string{ALGORITMO_ASTAR, ALGORITMO_JPS} {
+			b.Run(cenario.nome+"/"+algoritmo, func(b *testing.B) {
+				benchmarkBusca(b, g, OpcoesBusca{Modo: cenario.modo, Algoritmo: algoritmo, Heuristica: HEURISTICA_MST_BATALHAS})
+			})
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ---------------- Cenário (config.json) ----------------
+const ARQUIVO_CENARIO = "config.json"
+
+type Cenario struct {
+	Cavaleiros    []CavaleiroBronze    `json:"cavaleiros"`
+	Casas         []CasaZodiaco        `json:"casas_zodiaco"`
+	Configuracoes ConfiguracoesCenario `json:"configuracoes"`
+}
+
+type ConfiguracoesCenario struct {
+	TamanhoMapa   int            `json:"tamanho_mapa"`
+	Entrada       Point          `json:"entrada"`
+	GrandeMestre  Point          `json:"grande_mestre"`
+	CustosTerreno map[string]int `json:"custos_terreno"`
+	// Terrenos substituem os padrão de mesmo nome ou entram após eles
+	Terrenos []TipoTerreno `json:"terrenos"`
+	// Mapa opcional com índices de terreno; sem ele o terreno é gerado
+	Mapa [][]int `json:"mapa"`
+	// Movimento ortogonal quando omitido
+	Movimento Movimento `json:"movimento"`
+}
+
+func CarregarCenario(caminho string) (*Game, error) {
+	arquivo, err := os.Open(caminho)
+	if err != nil {
+		return nil, fmt.Errorf("abrindo cenário %s: %w", caminho, err)
+	}
+	defer arquivo.Close()
+
+	g, err := LerCenario(arquivo)
+	if err != nil {
+		return nil, fmt.Errorf("cenário %s: %w", caminho, err)
+	}
+	return g, nil
+}
+
+func LerCenario(r io.Reader) (*Game, error) {
+	var cenario Cenario
+	if err := json.NewDecoder(r).Decode(&cenario); err != nil {
+		return nil, erroDecodificacao(err)
+	}
+	return cenario.NovoJogo()
+}
+
+func (c Cenario) NovoJogo() (*Game, error) {
+	var erros ErrosValidacao
+	if c.Configuracoes.TamanhoMapa <= 0 {
+		erros.adicionar("configuracoes.tamanho_mapa", "deve ser positivo, recebido %d", c.Configuracoes.TamanhoMapa)
+	}
+
+	game := &Game{
+		Mapa:         c.Configuracoes.Mapa,
+		Size:         c.Configuracoes.TamanhoMapa,
+		Cavaleiros:   c.Cavaleiros,
+		Casas:        c.Casas,
+		Entrada:      c.Configuracoes.Entrada,
+		GrandeMestre: c.Configuracoes.GrandeMestre,
+		Movimento:    c.Configuracoes.Movimento,
+	}
+
+	validarTerrenos(c.Configuracoes.Terrenos, "configuracoes.terrenos", &erros)
+	game.Terrenos = mesclarTerrenos(TERRENOS_PADRAO, c.Configuracoes.Terrenos)
+
+	// Os custos do cenário valem apenas para este jogo
+	for nome, custo := range c.Configuracoes.CustosTerreno {
+		terreno := indiceTerreno(game.Terrenos, nome)
+		if terreno < 0 {
+			erros.adicionar("configuracoes.custos_terreno."+nome, "terreno desconhecido")
+			continue
+		}
+		if custo < 0 {
+			erros.adicionar("configuracoes.custos_terreno."+nome, "custo do terreno %s não pode ser negativo", nome)
+		}
+		game.Terrenos[terreno].Custo = custo
+	}
+
+	if len(erros) > 0 {
+		return nil, erros
+	}
+
+	if err := game.normalizar(); err != nil {
+		var conectividade ErroConectividade
+		if errors.As(err, &conectividade) {
+			conectividade.Erros = camposDoCenario(conectividade.Erros)
+			return nil, conectividade
+		}
+		var validacao ErrosValidacao
+		if errors.As(err, &validacao) {
+			return nil, camposDoCenario(validacao)
+		}
+		return nil, err
+	}
+	return game, nil
+}
+
+// JogoPadrao carrega o cenário indicado pela variável CENARIO (ou config.json)
+// e recorre ao jogo embutido em NovoJogo apenas quando o arquivo não existe.
+func JogoPadrao() (*Game, error) {
+	caminho := os.Getenv("CENARIO")
+	if caminho == "" {
+		caminho = ARQUIVO_CENARIO
+	}
+
+	g, err := CarregarCenario(caminho)
+	if errors.Is(err, fs.ErrNotExist) {
+		return NovoJogo(), nil
+	}
+	return g, err
+}
+
+// Os erros de Validar usam os campos do Game; no config.json eles ficam em
+// outros lugares.
+var CAMPOS_CENARIO = [][2]string{
+	{"casas", "casas_zodiaco"},
+	{"entrada", "configuracoes.entrada"},
+	{"grande_mestre", "configuracoes.grande_mestre"},
+	{"size", "configuracoes.tamanho_mapa"},
+	{"mapa", "configuracoes.mapa"},
+	{"terrenos", "configuracoes.terrenos"},
+	{"movimento", "configuracoes.movimento"},
+}
+
+func camposDoCenario(erros ErrosValidacao) ErrosValidacao {
+	traduzidos := make(ErrosValidacao, len(erros))
+	for i, erro := range erros {
+		for _, par := range CAMPOS_CENARIO {
+			if erro.Campo == par[0] || strings.HasPrefix(erro.Campo, par[0]+"[") || strings.HasPrefix(erro.Campo, par[0]+".") {
+				erro.Campo = par[1] + strings.TrimPrefix(erro.Campo, par[0])
+				break
+			}
+		}
+		traduzidos[i] = erro
+	}
+	return traduzidos
+}
+
+// ---------------- Cenário personalizado (formato do Game) ----------------
+const (
+	TAMANHO_MAXIMO_CENARIO = 8 << 20
+
+	// Lado máximo do mapa; sem mapa, o gerador limita a TAMANHO_MAXIMO_GERADO
+	TAMANHO_MAXIMO_MAPA = 512
+)
+
+// LerJogo decodifica um Game enviado pelo cliente. Sem "mapa", o terreno é
+// gerado como em NovoJogo; com "mapa", entrada, grande mestre e casas são
+// marcados na sobreposição, que é sempre recalculada. Sem "terrenos", o jogo
+// está no formato antigo e é migrado.
+func LerJogo(r io.Reader) (*Game, error) {
+	var g Game
+	if err := json.NewDecoder(r).Decode(&g); err != nil {
+		return nil, erroDecodificacao(err)
+	}
+	if err := g.normalizar(); err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// normalizar completa um Game recebido do cliente e o valida.
+func (g *Game) normalizar() error {
+	if len(g.Terrenos) == 0 {
+		g.migrarFormatoLegado()
+	}
+	g.Movimento.normalizar()
+	if len(g.Mapa) == 0 && g.Size > 0 {
+		// Recusado antes de alocar o mapa de Size² células
+		if g.Size > TAMANHO_MAXIMO_GERADO {
+			return ErrosValidacao{{Campo: "size", Mensagem: fmt.Sprintf("sem mapa, deve ser no máximo %d, recebido %d", TAMANHO_MAXIMO_GERADO, g.Size)}}
+		}
+		// O gerador usa os índices de MONTANHOSO, PLANO e ROCHOSO
+		if len(g.Terrenos) < len(TERRENOS_PADRAO) {
+			return ErrosValidacao{{Campo: "terrenos", Mensagem: fmt.Sprintf("sem mapa, são necessários ao menos %d terrenos para gerar o terreno", len(TERRENOS_PADRAO))}}
+		}
+		g.inicializarMapa()
+	} else if g.mapaConsistente() {
+		g.marcarPosicoes()
+	}
+	return g.Validar()
+}
+
+// JogoDaRequisicao usa o cenário do corpo em requisições POST (JSON, o mapa
+// em texto com Content-Type text/plain ou um PNG com image/png e a paleta no
+// parâmetro paleta). Nas demais, com o parâmetro seed gera um Santuário
+// procedural; sem ele, usa o cenário padrão.
+func JogoDaRequisicao(w http.ResponseWriter, r *http.Request) (*Game, error) {
+	if r.Method == http.MethodPost {
+		corpo := http.MaxBytesReader(w, r.Body, TAMANHO_MAXIMO_CENARIO)
+		switch tipo, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); tipo {
+		case "text/plain":
+			return LerMapaTexto(corpo)
+		case "image/png":
+			paleta, err := PaletaDaRequisicao(r)
+			if err != nil {
+				return nil, err
+			}
+			return LerMapaImagem(corpo, paleta)
+		}
+		return LerJogo(corpo)
+	}
+	if r.URL.Query().Has("seed") {
+		parametros, err := ParametrosDaRequisicao(r)
+		if err != nil {
+			return nil, err
+		}
+		return GerarSantuario(parametros)
+	}
+	return JogoPadrao()
+}
+
+func (g *Game) mapaConsistente() bool {
+	if g.Size <= 0 || len(g.Mapa) != g.Size {
+		return false
+	}
+	for _, linha := range g.Mapa {
+		if len(linha) != g.Size {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,194 @@
+package game
+
+import "math/bits"
+
+// ---------------- Estado compacto da busca ----------------
+// Um estado é a célula atual mais a máscara das casas conquistadas. A busca
+// consulta custos e casas em vetores planos, sem mapas nem alocações.
+
+const (
+	MAXIMO_CASAS = 64
+
+	// Até este número de estados o conjunto fechado é um vetor plano
+	LIMITE_ESTADOS_PLANOS = 1 << 23
+	// O vetor plano é alocado em páginas de 1 << BITS_PAGINA estados, só
+	// quando a busca escreve nelas: uma busca pequena não paga o espaço todo
+	BITS_PAGINA = 10
+)
+
+type estado struct {
+	celula  int32
+	mascara uint64
+}
+
+type grade struct {
+	tamanho int
+	custo   []int
+	casa    []int
+	// Só no movimento diagonal: custo de entrar na célula na diagonal
+	custoDiagonal []int
+	// Só no JPS: saltos ao longo de Y já calculados (veja saltarEmY)
+	saltosY []int32
+}
+
+func (g *Game) prepararGrade() grade {
+	gr := grade{
+		tamanho: g.Size,
+		custo:   make([]int, g.Size*g.Size),
+		casa:    make([]int, g.Size*g.Size),
+	}
+	if g.Movimento.diagonal() {
+		gr.custoDiagonal = make([]int, g.Size*g.Size)
+	}
+	for x := 0; x < g.Size; x++ {
+		for y := 0; y < g.Size; y++ {
+			p := Point{x, y}
+			celula := gr.celula(p)
+			gr.custo[celula] = g.custoMovimento(p)
+			if !g.transitavel(p) {
+				// Nunca é pisada; o custo negativo a tira do custoMinimo
+				gr.custo[celula] = -1
+			}
+			if gr.custoDiagonal != nil {
+				gr.custoDiagonal[celula] = g.Movimento.custoDiagonal(gr.custo[celula])
+			}
+			gr.casa[celula] = -1
+			if marco := g.Sobreposicao[x][y]; marco >= CASA_ZODIACO {
+				gr.casa[celula] = marco - CASA_ZODIACO
+			}
+		}
+	}
+	return gr
+}
+
+func (gr grade) celula(p Point) int {
+	return p.X*gr.tamanho + p.Y
+}
+
+// custoPasso é o custo de ir de de até a célula vizinha para.
+func (gr grade) custoPasso(de, para Point, celula int) int {
+	if gr.custoDiagonal != nil && de.X != para.X && de.Y != para.Y {
+		return gr.custoDiagonal[celula]
+	}
+	return gr.custo[celula]
+}
+
+func (gr grade) estado(p Point, mascara uint64) estado {
+	return estado{celula: int32(gr.celula(p)), mascara: mascara}
+}
+
+// conjuntoFechado guarda o melhor G de cada estado expandido. Com poucos
+// estados possíveis usa um vetor plano paginado (G+1, zero = ausente); quando
+// célula e máscara cabem juntas em 64 bits a chave é um inteiro; senão, o
+// próprio estado. Na ordem zodiacal a máscara é um prefixo e só o número de
+// casas importa.
+type conjuntoFechado struct {
+	bitsCelula uint
+	compacto   bool
+	ordenado   bool
+	planos     [][]int32
+	paginas    int
+	inteiros   map[uint64]int
+	amplos     map[estado]int
+}
+
+func novoConjuntoFechado(gr grade, casas int, ordenado bool) *conjuntoFechado {
+	c := &conjuntoFechado{ordenado: ordenado}
+	for (1 << c.bitsCelula) < gr.tamanho*gr.tamanho {
+		c.bitsCelula++
+	}
+
+	mascaras := casas + 1
+	if !ordenado && casas < 32 {
+		mascaras = 1 << casas
+	}
+	c.compacto = ordenado || int(c.bitsCelula)+casas <= 64
+
+	switch {
+	case c.compacto && (ordenado || casas < 32) && mascaras<<c.bitsCelula <= LIMITE_ESTADOS_PLANOS:
+		c.planos = make([][]int32, (mascaras<<c.bitsCelula+1<<BITS_PAGINA-1)>>BITS_PAGINA)
+	case c.compacto:
+		c.inteiros = make(map[uint64]int)
+	default:
+		c.amplos = make(map[estado]int)
+	}
+	return c
+}
+
+func (c *conjuntoFechado) chave(e estado) uint64 {
+	if c.ordenado {
+		return uint64(bits.OnesCount64(e.mascara))<<c.bitsCelula | uint64(e.celula)
+	}
+	return e.mascara<<c.bitsCelula | uint64(e.celula)
+}
+
+func (c *conjuntoFechado) obter(e estado) (int, bool) {
+	switch {
+	case c.planos != nil:
+		chave := c.chave(e)
+		pagina := c.planos[chave>>BITS_PAGINA]
+		if pagina == nil {
+			return 0, false
+		}
+		g := pagina[chave&(1<<BITS_PAGINA-1)]
+		return int(g) - 1, g != 0
+	case c.compacto:
+		g, existe := c.inteiros[c.chave(e)]
+		return g, existe
+	}
+	g, existe := c.amplos[e]
+	return g, existe
+}
+
+func (c *conjuntoFechado) registrar(e estado, g int) {
+	switch {
+	case c.planos != nil:
+		chave := c.chave(e)
+		pagina := c.planos[chave>>BITS_PAGINA]
+		if pagina == nil {
+			pagina = make([]int32, 1<<BITS_PAGINA)
+			c.planos[chave>>BITS_PAGINA] = pagina
+			c.paginas++
+		}
+		pagina[chave&(1<<BITS_PAGINA-1)] = int32(g + 1)
+	case c.compacto:
+		c.inteiros[c.chave(e)] = g
+	default:
+		c.amplos[e] = g
+	}
+}
+
+// bytes estima a memória do conjunto fechado (chave, valor e o controle do mapa)
+func (c *conjuntoFechado) bytes() int64 {
+	switch {
+	case c.planos != nil:
+		return int64(c.paginas)<<BITS_PAGINA*4 + int64(len(c.planos))*24
+	case c.compacto:
+		return int64(len(c.inteiros)) * (8 + 8 + 1)
+	}
+	return int64(len(c.amplos)) * (16 + 8 + 1)
+}
+
+func mascaraCompleta(casas int) uint64 {
+	if casas >= 64 {
+		return ^uint64(0)
+	}
+	return 1<<casas - 1
+}
+
+// indicesDaMascara lista as casas conquistadas em ordem crescente.
+func indicesDaMascara(mascara uint64) []int {
+	indices := []int{}
+	for ; mascara != 0; mascara &= mascara - 1 {
+		indices = append(indices, bits.TrailingZeros64(mascara))
+	}
+	return indices
+}
+
+func casasDaMascara(mascara uint64, casas int) []bool {
+	visitadas := make([]bool, casas)
+	for i := range visitadas {
+		visitadas[i] = mascara&(1<<i) != 0
+	}
+	return visitadas
+}
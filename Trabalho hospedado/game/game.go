@@ -2,28 +2,23 @@ package game
 
 import (
 	"container/heap"
+	"context"
 	"fmt"
 	"math"
+	"math/bits"
 	"net/http"
 	"time"
+	"unsafe"
 )
 
 // ---------------- Constantes ----------------
+// Índices dos terrenos padrão em Game.Terrenos
 const (
-	MONTANHOSO    = 0
-	PLANO         = 1
-	ROCHOSO       = 2
-	ENTRADA       = 3
-	GRANDE_MESTRE = 4
-	CASA_ZODIACO  = 5
+	MONTANHOSO = 0
+	PLANO      = 1
+	ROCHOSO    = 2
 )
 
-var CUSTOS_TERRENO = map[int]int{
-	MONTANHOSO: 200,
-	PLANO:      1,
-	ROCHOSO:    5,
-}
-
 // ---------------- Structs ----------------
 type CavaleiroBronze struct {
 	Nome         string  `json:"nome"`
@@ -49,7 +44,8 @@ type Node struct {
 	F       int
 	Parent  *Node
 	CasaID  int
-	Visited []bool
+	Visited uint64
+	Passos  int
 }
 
 type PriorityQueue []*Node
@@ -71,201 +67,118 @@ func (pq *PriorityQueue) Pop() interface{} {
 }
 
 type Game struct {
+	// Mapa guarda o índice do terreno de cada célula em Terrenos
 	Mapa         [][]int           `json:"mapa"`
+	Terrenos     []TipoTerreno     `json:"terrenos"`
+	Sobreposicao [][]int           `json:"sobreposicao"`
 	Cavaleiros   []CavaleiroBronze `json:"cavaleiros"`
 	Casas        []CasaZodiaco     `json:"casas"`
 	Entrada      Point             `json:"entrada"`
 	GrandeMestre Point             `json:"grande_mestre"`
 	Size         int               `json:"size"`
+	Movimento    Movimento         `json:"movimento"`
+
+	// Formato antigo, sem Terrenos: custos por índice, migrados em normalizar
+	CustosTerreno map[int]int `json:"custos_terreno,omitempty"`
 }
 
 type ResultadoBusca struct {
-	Sucesso      bool          `json:"sucesso"`
-	Caminho      []Point       `json:"caminho"`
-	CustoTotal   int           `json:"custo_total"`
-	Duracao      string        `json:"duracao"`
-	Estatisticas Estatisticas  `json:"estatisticas"`
+	Sucesso bool   `json:"sucesso"`
+	Motivo  string `json:"motivo,omitempty"`
+	// Abortada traz o motivo da interrupção (cancelada, limite_nos ou
+	// limite_tempo); o caminho, se houver, leva ao melhor nó alcançado
+	Abortada   string `json:"abortada,omitempty"`
+	Modo       string `json:"modo"`
+	Algoritmo  string `json:"algoritmo"`
+	Heuristica string `json:"heuristica"`
+	// OtimoGarantido indica que algoritmo, heurística e plano de batalhas
+	// juntos garantem o menor CustoTotal possível
+	OtimoGarantido bool   `json:"otimo_garantido"`
+	Objetivo       string `json:"objetivo"`
+	// Prazo em minutos e se CustoTotal coube nele
+	Prazo         int     `json:"prazo"`
+	DentroDoPrazo bool    `json:"dentro_do_prazo"`
+	Caminho       []Point `json:"caminho"`
+	// Trajeto traz os vértices da polilinha quando o caminho é em qualquer
+	// ângulo (theta), feito de saltos (jps) ou suavizado; Caminho tem todas
+	// as células dele
+	Trajeto      []Point      `json:"trajeto,omitempty"`
+	CustoTotal   int          `json:"custo_total"`
+	Batalhas     []EtapaCasa  `json:"batalhas"`
+	Duracao      string       `json:"duracao"`
+	Estatisticas Estatisticas `json:"estatisticas"`
 }
 
 type Estatisticas struct {
 	TamanhoCaminho     int     `json:"tamanho_caminho"`
 	CustoMedioPorPasso float64 `json:"custo_medio_por_passo"`
 	CasasVisitadas     []bool  `json:"casas_visitadas"`
+	CustoCaminhada     int     `json:"custo_caminhada"`
+	CustoBatalhas      int     `json:"custo_batalhas"`
 	TempoExecucao      string  `json:"tempo_execucao"`
+
+	// Instrumentação da busca
+	NosExpandidos      int   `json:"nos_expandidos"`
+	NosGerados         int   `json:"nos_gerados"`
+	NosReabertos       int   `json:"nos_reabertos"`
+	EstadosDescartados int   `json:"estados_descartados"`
+	PicoFronteira      int   `json:"pico_fronteira"`
+	MemoriaAproximada  int64 `json:"memoria_aproximada"`
 }
 
 // ---------------- Inicialização ----------------
+var CAVALEIROS_PADRAO = []CavaleiroBronze{
+	{"Seiya", 1.5, 5},
+	{"Shiryu", 1.4, 5},
+	{"Hyoga", 1.3, 5},
+	{"Shun", 1.2, 5},
+	{"Ikki", 1.1, 5},
+}
+
+// Novas posições das casas conforme solicitado. Os mapas gerados, importados
+// de imagem ou de texto aproveitam só nomes e dificuldades.
+var CASAS_PADRAO = []CasaZodiaco{
+	{"Áries", 50, Point{5, 31}},
+	{"Touro", 55, Point{5, 14}},
+	{"Gêmeos", 60, Point{10, 15}},
+	{"Câncer", 70, Point{10, 28}},
+	{"Leão", 75, Point{14, 38}},
+	{"Virgem", 80, Point{18, 30}},
+	{"Libra", 85, Point{18, 10}},
+	{"Escorpião", 90, Point{25, 10}},
+	{"Sagitário", 95, Point{25, 27}},
+	{"Capricórnio", 100, Point{32, 34}},
+	{"Aquário", 110, Point{32, 18}},
+	{"Peixes", 120, Point{38, 22}},
+}
+
+func cavaleirosPadrao() []CavaleiroBronze {
+	return append([]CavaleiroBronze(nil), CAVALEIROS_PADRAO...)
+}
+
 func NovoJogo() *Game {
 	game := &Game{
-		Size: 42,
-		Cavaleiros: []CavaleiroBronze{
-			{"Seiya", 1.5, 5},
-			{"Shiryu", 1.4, 5},
-			{"Hyoga", 1.3, 5},
-			{"Shun", 1.2, 5},
-			{"Ikki", 1.1, 5},
-		},
-		// Novas posições das casas conforme solicitado
-		Casas: []CasaZodiaco{
-			{"Áries", 50, Point{5, 31}},
-			{"Touro", 55, Point{5, 14}},
-			{"Gêmeos", 60, Point{10, 15}},
-			{"Câncer", 70, Point{10, 28}},
-			{"Leão", 75, Point{14, 38}},
-			{"Virgem", 80, Point{18, 30}},
-			{"Libra", 85, Point{18, 10}},
-			{"Escorpião", 90, Point{25, 10}},
-			{"Sagitário", 95, Point{25, 27}},
-			{"Capricórnio", 100, Point{32, 34}},
-			{"Aquário", 110, Point{32, 18}},
-			{"Peixes", 120, Point{38, 22}},
-		},
+		Size:       42,
+		Cavaleiros: cavaleirosPadrao(),
+		Casas:      append([]CasaZodiaco(nil), CASAS_PADRAO...),
 		// Novas posições de entrada e saída
 		Entrada:      Point{5, 38},
 		GrandeMestre: Point{38, 38},
+		Movimento:    Movimento{Tipo: MOVIMENTO_ORTOGONAL},
 	}
 
 	game.inicializarMapa()
 	return game
 }
 
+// inicializarMapa gera o terreno em volta dos marcos com o gerador
+// procedural e a semente SEMENTE_PADRAO, sempre o mesmo para os mesmos marcos.
 func (g *Game) inicializarMapa() {
-	g.Mapa = make([][]int, g.Size)
-	
-	// Inicializar todo o mapa como MONTANHOSO primeiro
-	for i := range g.Mapa {
-		g.Mapa[i] = make([]int, g.Size)
-		for j := range g.Mapa[i] {
-			g.Mapa[i][j] = MONTANHOSO
-		}
-	}
-
-	// Criar caminhos entre as casas usando apenas terreno PLANO e ROCHOSO
-	g.criarCaminhos()
-
-	// Definir posições especiais
-	g.Mapa[g.Entrada.X][g.Entrada.Y] = ENTRADA
-	g.Mapa[g.GrandeMestre.X][g.GrandeMestre.Y] = GRANDE_MESTRE
-
-	// Posicionar as casas do zodíaco
-	for i, casa := range g.Casas {
-		if casa.Posicao.X >= 0 && casa.Posicao.X < g.Size &&
-			casa.Posicao.Y >= 0 && casa.Posicao.Y < g.Size {
-			g.Mapa[casa.Posicao.X][casa.Posicao.Y] = CASA_ZODIACO + i
-		}
-	}
-}
-
-func (g *Game) criarCaminhos() {
-	// Lista de todos os pontos importantes (entrada, casas, grande mestre)
-	pontosImportantes := []Point{g.Entrada}
-	for _, casa := range g.Casas {
-		pontosImportantes = append(pontosImportantes, casa.Posicao)
-	}
-	pontosImportantes = append(pontosImportantes, g.GrandeMestre)
-
-	// Criar caminhos entre todos os pontos importantes
-	for i := 0; i < len(pontosImportantes); i++ {
-		for j := i + 1; j < len(pontosImportantes); j++ {
-			g.criarCaminhoEntrePontos(pontosImportantes[i], pontosImportantes[j])
-		}
-	}
-
-	// Criar área navegável ao redor das casas (lados esquerdo e direito)
-	for _, casa := range g.Casas {
-		g.criarAreaNavegavel(casa.Posicao)
-	}
-	
-	// Criar área navegável ao redor da entrada e grande mestre
-	g.criarAreaNavegavel(g.Entrada)
-	g.criarAreaNavegavel(g.GrandeMestre)
-}
-
-func (g *Game) criarCaminhoEntrePontos(inicio, fim Point) {
-	// Usar algoritmo simples para criar caminho em linha reta com variações
-	x1, y1 := inicio.X, inicio.Y
-	x2, y2 := fim.X, fim.Y
-
-	// Diferenças
-	dx := x2 - x1
-	dy := y2 - y1
-
-	// Número de passos
-	passos := int(math.Max(math.Abs(float64(dx)), math.Abs(float64(dy))))
-
-	if passos == 0 {
-		return
-	}
-
-	// Incrementos
-	stepX := float64(dx) / float64(passos)
-	stepY := float64(dy) / float64(passos)
-
-	// Criar o caminho
-	for i := 0; i <= passos; i++ {
-		x := int(math.Round(float64(x1) + stepX*float64(i)))
-		y := int(math.Round(float64(y1) + stepY*float64(i)))
-
-		if x >= 0 && x < g.Size && y >= 0 && y < g.Size {
-			// Alternar entre PLANO e ROCHOSO para variedade
-			if (x+y)%2 == 0 {
-				g.Mapa[x][y] = PLANO
-			} else {
-				g.Mapa[x][y] = ROCHOSO
-			}
-		}
-	}
-}
-
-func (g *Game) criarAreaNavegavel(centro Point) {
-	// Criar uma área 5x5 ao redor do ponto central
-	for dx := -2; dx <= 2; dx++ {
-		for dy := -2; dy <= 2; dy++ {
-			x := centro.X + dx
-			y := centro.Y + dy
-
-			if x >= 0 && x < g.Size && y >= 0 && y < g.Size {
-				// Só modificar se ainda for terreno montanhoso
-				if g.Mapa[x][y] == MONTANHOSO {
-					// Alternar entre PLANO e ROCHOSO
-					if (x+y)%2 == 0 {
-						g.Mapa[x][y] = PLANO
-					} else {
-						g.Mapa[x][y] = ROCHOSO
-					}
-				}
-			}
-		}
-	}
-
-	// Criar corredores laterais (esquerda e direita)
-	for i := -5; i <= 5; i++ {
-		// Corredor à esquerda
-		if centro.Y-3 >= 0 {
-			x := centro.X + i
-			y := centro.Y - 3
-			if x >= 0 && x < g.Size && g.Mapa[x][y] == MONTANHOSO {
-				if (x+y)%2 == 0 {
-					g.Mapa[x][y] = PLANO
-				} else {
-					g.Mapa[x][y] = ROCHOSO
-				}
-			}
-		}
-
-		// Corredor à direita
-		if centro.Y+3 < g.Size {
-			x := centro.X + i
-			y := centro.Y + 3
-			if x >= 0 && x < g.Size && g.Mapa[x][y] == MONTANHOSO {
-				if (x+y)%2 == 0 {
-					g.Mapa[x][y] = PLANO
-				} else {
-					g.Mapa[x][y] = ROCHOSO
-				}
-			}
-		}
+	if len(g.Terrenos) == 0 {
+		g.Terrenos = terrenosPadrao()
 	}
+	g.gerarTerreno(SEMENTE_PADRAO, PARAMETROS_PADRAO.Densidade, PARAMETROS_PADRAO.Sinuosidade)
+	g.marcarPosicoes()
 }
 
 // ---------------- Utilidades ----------------
@@ -278,33 +191,36 @@ func (g *Game) posicaoValida(p Point) bool {
 }
 
 func (g *Game) obterVizinhos(p Point) []Point {
-	vizinhos := []Point{
-		{p.X - 1, p.Y},
-		{p.X + 1, p.Y},
-		{p.X, p.Y - 1},
-		{p.X, p.Y + 1},
+	return g.vizinhosEm(p, nil)
+}
+
+// vizinhosEm acrescenta os vizinhos válidos a dst, evitando alocação na busca
+func (g *Game) vizinhosEm(p Point, dst []Point) []Point {
+	for _, d := range DIRECOES_ORTOGONAIS {
+		if v := (Point{p.X + d.X, p.Y + d.Y}); g.posicaoValida(v) && g.transitavel(v) {
+			dst = append(dst, v)
+		}
 	}
 
-	var validosResult []Point
-	for _, v := range vizinhos {
-		if g.posicaoValida(v) {
-			validosResult = append(validosResult, v)
+	switch g.Movimento.Tipo {
+	case MOVIMENTO_DIAGONAL:
+		for _, d := range DIRECOES_DIAGONAIS {
+			if v := (Point{p.X + d.X, p.Y + d.Y}); g.posicaoValida(v) && g.transitavel(v) && !g.cortaQuina(p, d) {
+				dst = append(dst, v)
+			}
+		}
+	case MOVIMENTO_HEXAGONAL:
+		for _, d := range DIRECOES_HEXAGONAIS[len(DIRECOES_ORTOGONAIS):] {
+			if v := (Point{p.X + d.X, p.Y + d.Y}); g.posicaoValida(v) && g.transitavel(v) {
+				dst = append(dst, v)
+			}
 		}
 	}
-	return validosResult
+	return dst
 }
 
 func (g *Game) custoMovimento(p Point) int {
-	terreno := g.Mapa[p.X][p.Y]
-
-	if terreno >= CASA_ZODIACO {
-		return CUSTOS_TERRENO[PLANO]
-	}
-
-	if custo, existe := CUSTOS_TERRENO[terreno]; existe {
-		return custo
-	}
-	return CUSTOS_TERRENO[PLANO]
+	return g.Terrenos[g.Mapa[p.X][p.Y]].Custo
 }
 
 func (g *Game) tempoBatalha(casaID int, cavaleirosParticipantes []int) float64 {
@@ -328,130 +244,291 @@ func (g *Game) tempoBatalha(casaID int, cavaleirosParticipantes []int) float64 {
 	return float64(casa.Dificuldade) / somaPoderCosmico
 }
 
-func todasCasasVisitadas(visited []bool) bool {
-	for _, v := range visited {
-		if !v {
-			return false
-		}
-	}
-	return true
-}
-
 // ---------------- Algoritmo A* ----------------
 func (g *Game) AStar() ResultadoBusca {
+	return g.Buscar(OpcoesBusca{Algoritmo: ALGORITMO_ASTAR})
+}
+
+// buscaMelhorPrimeiro percorre o espaço (célula, casas conquistadas) expandindo
+// sempre o nó de menor prioridade; a estratégia define a prioridade e se um
+// estado já expandido pode ser reaberto ao ser alcançado com G menor.
+func (g *Game) buscaMelhorPrimeiro(ctx context.Context, opcoes OpcoesBusca, estrategia estrategiaBusca) ResultadoBusca {
 	inicio := time.Now()
+	limites := novoOrcamento(ctx, opcoes)
+	var estatisticas Estatisticas
+
+	falha := func(motivo string) ResultadoBusca {
+		return resultadoFalha(opcoes, motivo, estatisticas, inicio)
+	}
+
+	if len(g.Casas) > MAXIMO_CASAS {
+		return falha(fmt.Sprintf("no máximo %d casas, recebidas %d", MAXIMO_CASAS, len(g.Casas)))
+	}
+	// Na ordem zodiacal a máscara é sempre um prefixo: o estado equivale ao
+	// índice da próxima casa
+	ordenado := opcoes.Modo == MODO_ORDEM_ZODIACAL
+	// Os saltos são retas de 4 vizinhos; nos outros movimentos, A* comum
+	if g.Movimento.Tipo != MOVIMENTO_ORTOGONAL {
+		estrategia.saltos = false
+	}
+
+	plano, err := g.planejarBatalhas()
+	if err != nil {
+		return falha(err.Error())
+	}
+
+	grade := g.prepararGrade()
+	if estrategia.saltos {
+		grade.saltosY = make([]int32, 2*len(grade.custo))
+	}
+	completa := mascaraCompleta(len(g.Casas))
+	tempos := make([]int, len(g.Casas))
+	for i, batalha := range plano.Batalhas {
+		tempos[i] = minutosBatalha(batalha.Tempo)
+	}
 
 	openSet := &PriorityQueue{}
 	heap.Init(openSet)
 
-	inicialVisited := make([]bool, len(g.Casas))
+	heuristica, err := ObterHeuristica(opcoes.Heuristica)
+	if err != nil {
+		return falha(err.Error())
+	}
+	estimar := heuristica.Preparar(contextoHeuristica{g: g, grade: grade, tempos: tempos, ordenado: ordenado})
+
+	h0 := estimar(g.Entrada, 0)
 	inicial := &Node{
-		Point:   g.Entrada,
-		G:       0,
-		H:       distanciaManhattan(g.Entrada, g.GrandeMestre),
-		F:       distanciaManhattan(g.Entrada, g.GrandeMestre),
-		Parent:  nil,
-		CasaID:  -1,
-		Visited: inicialVisited,
+		Point:  g.Entrada,
+		G:      0,
+		H:      h0,
+		F:      estrategia.prioridade(0, h0, 0),
+		Parent: nil,
+		CasaID: -1,
 	}
 
 	heap.Push(openSet, inicial)
-	visited := make(map[string]*Node)
+	fechados := novoConjuntoFechado(grade, len(g.Casas), ordenado)
+	vizinhos := make([]Point, 0, 4)
+	estatisticas.NosGerados = 1
+	estatisticas.PicoFronteira = 1
+
+	// Memória: nós alocados, fila de prioridade e conjunto fechado
+	medirMemoria := func() {
+		estatisticas.MemoriaAproximada = int64(estatisticas.NosGerados)*int64(unsafe.Sizeof(Node{})) +
+			int64(cap(*openSet))*int64(unsafe.Sizeof(inicial)) + fechados.bytes()
+	}
+
+	// Melhor nó expandido, devolvido se a busca for interrompida
+	var melhor *Node
 
 	for openSet.Len() > 0 {
-		atual := heap.Pop(openSet).(*Node)
+		if motivo := limites.esgotado(estatisticas.NosExpandidos); motivo != "" {
+			medirMemoria()
+			return g.resultadoParcial(opcoes, motivo, melhor, plano, estatisticas, inicio)
+		}
 
-		chave := fmt.Sprintf("%d,%d,%v", atual.X, atual.Y, atual.Visited)
+		atual := heap.Pop(openSet).(*Node)
 
-		if existente, existe := visited[chave]; existe {
-			if existente.G <= atual.G {
-				continue
-			}
+		chave := grade.estado(atual.Point, atual.Visited)
+		melhorG, existe := fechados.obter(chave)
+		if existe && (!estrategia.reabrir || melhorG <= atual.G) {
+			estatisticas.EstadosDescartados++
+			continue
+		}
+		if existe {
+			estatisticas.NosReabertos++
+		}
+		fechados.registrar(chave, atual.G)
+		estatisticas.NosExpandidos++
+		if melhor == nil || maisAvancado(atual, melhor) {
+			melhor = atual
+		}
+		if opcoes.Rastrear != nil {
+			opcoes.Rastrear(PassoRastro{
+				X: atual.X, Y: atual.Y,
+				G: atual.G, H: atual.H, F: atual.F,
+				Casas:     atual.Visited,
+				Fronteira: openSet.Len(),
+			})
 		}
-		visited[chave] = atual
 
-		if atual.Point == g.GrandeMestre && todasCasasVisitadas(atual.Visited) {
-			var caminho []Point
-			no := atual
-			custoTotal := atual.G
+		if atual.Point == g.GrandeMestre && atual.Visited == completa {
+			medirMemoria()
+			resultado := g.resultadoDoCaminho(opcoes, caminhoAte(atual), plano, estatisticas, inicio)
+			resultado.Heuristica = heuristica.Nome()
+			resultado.OtimoGarantido = estrategia.otima(heuristica) && plano.Otima
+			return resultado
+		}
 
-			for no != nil {
-				caminho = append([]Point{no.Point}, caminho...)
-				no = no.Parent
+		vizinhos = g.vizinhosEm(atual.Point, vizinhos[:0])
+		if estrategia.saltos {
+			vizinhos = g.saltosEm(grade, atual, vizinhos)
+		}
+		for _, vizinho := range vizinhos {
+			celula := grade.celula(vizinho)
+			custo, distancia := grade.custoPasso(atual.Point, vizinho, celula), 1
+			if estrategia.saltos {
+				// Um salto é uma reta sobre células do custo da célula final
+				distancia = distanciaManhattan(atual.Point, vizinho)
+				custo *= distancia
 			}
-
-			duracao := time.Since(inicio)
-
-			return ResultadoBusca{
-				Sucesso:    true,
-				Caminho:    caminho,
-				CustoTotal: custoTotal,
-				Duracao:    duracao.String(),
-				Estatisticas: Estatisticas{
-					TamanhoCaminho:     len(caminho),
-					CustoMedioPorPasso: float64(custoTotal) / float64(len(caminho)),
-					CasasVisitadas:     atual.Visited,
-					TempoExecucao:      duracao.String(),
-				},
+			novoG := atual.G + custo
+			mascara := atual.Visited
+			pai, passos := atual, atual.Passos+distancia
+
+			// Theta*: com linha de visada do pai de atual até o vizinho, liga
+			// o vizinho direto ao pai quando a reta não sai mais cara. Se atual
+			// foi uma batalha, o atalho a pularia e não é tentado.
+			if estrategia.qualquerAngulo && atual.Parent != nil && atual.Parent.Visited == atual.Visited {
+				if celulas, custo, livre := g.segmento(grade, atual.Parent.Point, vizinho, mascara); livre && atual.Parent.G+custo <= novoG {
+					pai, novoG, passos = atual.Parent, atual.Parent.G+custo, atual.Parent.Passos+celulas
+				}
 			}
-		}
 
-		for _, vizinho := range g.obterVizinhos(atual.Point) {
-			custoMovimento := g.custoMovimento(vizinho)
-			novoG := atual.G + custoMovimento
-
-			terreno := g.Mapa[vizinho.X][vizinho.Y]
-			casaID := -1
-			novasVisited := make([]bool, len(atual.Visited))
-			copy(novasVisited, atual.Visited)
-
-			if terreno >= CASA_ZODIACO {
-				casaID = terreno - CASA_ZODIACO
-				if casaID < len(g.Casas) {
-					cavaleirosDisponiveis := []int{}
-					for i := range g.Cavaleiros {
-						if g.Cavaleiros[i].Energia > 0 {
-							cavaleirosDisponiveis = append(cavaleirosDisponiveis, i)
-						}
-					}
-
-					if len(cavaleirosDisponiveis) > 0 {
-						tempoBatalha := g.tempoBatalha(casaID, cavaleirosDisponiveis)
-						novoG += int(tempoBatalha)
-						novasVisited[casaID] = true
-					}
+			// Só há batalha na primeira vez que a casa é alcançada, com a
+			// equipe escolhida pelo plano de batalhas. Na ordem zodiacal as
+			// casas ainda não liberadas bloqueiam a passagem.
+			casaID := grade.casa[celula]
+			if casaID >= 0 && mascara&(1<<casaID) == 0 {
+				if ordenado && casaID != bits.OnesCount64(mascara) {
+					continue
 				}
+				novoG += tempos[casaID]
+				mascara |= 1 << casaID
 			}
 
-			h := distanciaManhattan(vizinho, g.GrandeMestre)
-
-			casasRestantes := 0
-			for _, visitada := range novasVisited {
-				if !visitada {
-					casasRestantes++
-				}
+			// Estados já expandidos com custo menor nem entram na fila
+			if melhorG, existe := fechados.obter(grade.estado(vizinho, mascara)); existe && (!estrategia.reabrir || melhorG <= novoG) {
+				estatisticas.EstadosDescartados++
+				continue
 			}
-			h += casasRestantes * 50
+
+			h := estimar(vizinho, mascara)
 
 			novoNo := &Node{
 				Point:   vizinho,
 				G:       novoG,
 				H:       h,
-				F:       novoG + h,
-				Parent:  atual,
+				F:       estrategia.prioridade(novoG, h, passos),
+				Parent:  pai,
 				CasaID:  casaID,
-				Visited: novasVisited,
+				Visited: mascara,
+				Passos:  passos,
 			}
 
 			heap.Push(openSet, novoNo)
+			estatisticas.NosGerados++
+		}
+
+		if openSet.Len() > estatisticas.PicoFronteira {
+			estatisticas.PicoFronteira = openSet.Len()
 		}
 	}
 
+	medirMemoria()
+	return falha("nenhum caminho passa por todas as casas até o Grande Mestre")
+}
+
+// resultadoDoCaminho monta o resultado de sucesso a partir do caminho completo,
+// refazendo o cronograma das batalhas com os mesmos custos da busca. O
+// caminho pode vir como trajeto (vértices do Theta*); com opcoes.Suavizar ele
+// é suavizado. Em ambos os casos o custo é o das células atravessadas.
+func (g *Game) resultadoDoCaminho(opcoes OpcoesBusca, caminho []Point, plano Atribuicao, estatisticas Estatisticas, inicio time.Time) ResultadoBusca {
+	var trajeto []Point
+	if celulas := g.percorrer(caminho); len(celulas) != len(caminho) {
+		trajeto, caminho = caminho, celulas
+	}
+	if opcoes.Suavizar {
+		trajeto = g.suavizarCaminho(caminho)
+		caminho = g.percorrer(trajeto)
+	}
+
+	duracao := time.Since(inicio)
+	etapas, caminhada, batalhas := g.cronograma(caminho, plano)
+	custoTotal := caminhada + batalhas
+
+	estatisticas.TamanhoCaminho = len(caminho)
+	estatisticas.CustoMedioPorPasso = float64(custoTotal) / float64(len(caminho))
+	estatisticas.CasasVisitadas = make([]bool, len(g.Casas))
+	for _, etapa := range etapas {
+		estatisticas.CasasVisitadas[etapa.CasaID] = true
+	}
+	estatisticas.CustoCaminhada = caminhada
+	estatisticas.CustoBatalhas = batalhas
+	estatisticas.TempoExecucao = duracao.String()
+
+	return ResultadoBusca{
+		Sucesso:    true,
+		Modo:       opcoes.Modo,
+		Algoritmo:  opcoes.Algoritmo,
+		Heuristica: opcoes.Heuristica,
+		Caminho:    caminho,
+		Trajeto:    trajeto,
+		CustoTotal: custoTotal,
+		Batalhas:   etapas,
+		Duracao:    duracao.String(),
+
+		Estatisticas: estatisticas,
+	}
+}
+
+func resultadoFalha(opcoes OpcoesBusca, motivo string, estatisticas Estatisticas, inicio time.Time) ResultadoBusca {
 	duracao := time.Since(inicio)
+	estatisticas.TempoExecucao = duracao.String()
 	return ResultadoBusca{
-		Sucesso: false,
-		Duracao: duracao.String(),
+		Sucesso:      false,
+		Motivo:       motivo,
+		Modo:         opcoes.Modo,
+		Algoritmo:    opcoes.Algoritmo,
+		Heuristica:   opcoes.Heuristica,
+		Duracao:      duracao.String(),
+		Estatisticas: estatisticas,
+	}
+}
+
+// resultadoParcial descreve uma busca interrompida com o caminho até o melhor
+// nó expandido, sem garantia alguma de que ele leve ao Grande Mestre.
+func (g *Game) resultadoParcial(opcoes OpcoesBusca, motivo string, melhor *Node, plano Atribuicao, estatisticas Estatisticas, inicio time.Time) ResultadoBusca {
+	resultado := ResultadoBusca{
+		Modo:         opcoes.Modo,
+		Algoritmo:    opcoes.Algoritmo,
+		Heuristica:   opcoes.Heuristica,
+		Estatisticas: estatisticas,
+	}
+	if melhor != nil {
+		resultado = g.resultadoDoCaminho(opcoes, caminhoAte(melhor), plano, estatisticas, inicio)
+	}
+	duracao := time.Since(inicio)
+	resultado.Sucesso = false
+	resultado.Motivo = descreverInterrupcao(motivo, estatisticas.NosExpandidos)
+	resultado.Abortada = motivo
+	resultado.Duracao = duracao.String()
+	resultado.Estatisticas.TempoExecucao = duracao.String()
+	return resultado
+}
+
+func caminhoAte(no *Node) []Point {
+	var caminho []Point
+	for ; no != nil; no = no.Parent {
+		caminho = append(caminho, no.Point)
+	}
+	for i, j := 0, len(caminho)-1; i < j; i, j = i+1, j-1 {
+		caminho[i], caminho[j] = caminho[j], caminho[i]
+	}
+	return caminho
+}
+
+// maisAvancado compara nós pelo número de casas conquistadas, depois pela
+// menor estimativa restante e por fim pelo menor custo acumulado.
+func maisAvancado(a, b *Node) bool {
+	casasA, casasB := bits.OnesCount64(a.Visited), bits.OnesCount64(b.Visited)
+	if casasA != casasB {
+		return casasA > casasB
+	}
+	if a.H != b.H {
+		return a.H < b.H
 	}
+	return a.G < b.G
 }
 
 // ---------------- Utils ----------------
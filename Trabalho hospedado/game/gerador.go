@@ -0,0 +1,340 @@
+package game
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// ---------------- Gerador procedural do Santuário ----------------
+// A mesma semente e os mesmos parâmetros geram sempre o mesmo mapa. Um ruído
+// de valor suavizado espalha as montanhas; as casas são sorteadas afastadas
+// umas das outras; e corredores de PLANO e ROCHOSO, abertos por passeios
+// aleatórios puxados para o destino, ligam entrada, casas (na ordem) e
+// grande mestre. Como cada passeio termina no seu destino, todo marco é
+// alcançável pela entrada sem pisar em montanha. O mapa de NovoJogo e dos
+// cenários sem mapa sai das mesmas etapas, com os marcos fixos e a semente
+// SEMENTE_PADRAO.
+
+const (
+	TAMANHO_MINIMO_GERADO = 12
+	TAMANHO_MAXIMO_GERADO = 256
+
+	// Tentativas de sortear uma casa antes de reduzir o afastamento mínimo
+	TENTATIVAS_POSICAO = 200
+
+	// Semente do terreno de NovoJogo e dos cenários sem mapa
+	SEMENTE_PADRAO = 42
+)
+
+type ParametrosGeracao struct {
+	Semente int64 `json:"semente"`
+	Tamanho int   `json:"tamanho"`
+	Casas   int   `json:"casas"`
+	// Densidade é a fração aproximada do mapa coberta por montanhas (0 a 1)
+	Densidade float64 `json:"densidade"`
+	// Sinuosidade é a chance de cada passo do corredor desviar do destino (0 a 1)
+	Sinuosidade float64 `json:"sinuosidade"`
+}
+
+var PARAMETROS_PADRAO = ParametrosGeracao{
+	Tamanho:     42,
+	Casas:       12,
+	Densidade:   0.6,
+	Sinuosidade: 0.35,
+}
+
+func (p ParametrosGeracao) Validar() error {
+	var erros ErrosValidacao
+	if p.Tamanho < TAMANHO_MINIMO_GERADO || p.Tamanho > TAMANHO_MAXIMO_GERADO {
+		erros.adicionar("tamanho", "deve estar entre %d e %d, recebido %d", TAMANHO_MINIMO_GERADO, TAMANHO_MAXIMO_GERADO, p.Tamanho)
+	}
+	// Nomes, dificuldades e a energia dos cavaleiros vêm do jogo padrão
+	if maximo := len(CASAS_PADRAO); p.Casas < 1 || p.Casas > maximo {
+		erros.adicionar("casas", "deve estar entre 1 e %d, recebido %d", maximo, p.Casas)
+	}
+	if p.Densidade < 0 || p.Densidade > 1 {
+		erros.adicionar("densidade", "deve estar entre 0 e 1, recebido %g", p.Densidade)
+	}
+	if p.Sinuosidade < 0 || p.Sinuosidade > 1 {
+		erros.adicionar("sinuosidade", "deve estar entre 0 e 1, recebido %g", p.Sinuosidade)
+	}
+	if len(erros) > 0 {
+		return erros
+	}
+	return nil
+}
+
+// GerarSantuario cria um jogo novo a partir dos parâmetros.
+func GerarSantuario(p ParametrosGeracao) (*Game, error) {
+	if err := p.Validar(); err != nil {
+		return nil, err
+	}
+	rng := rand.New(rand.NewSource(p.Semente))
+	g := &Game{
+		Size:       p.Tamanho,
+		Terrenos:   terrenosPadrao(),
+		Cavaleiros: cavaleirosPadrao(),
+	}
+
+	g.espalharMontanhas(rng, p.Densidade)
+
+	// Entrada no terço de cima, grande mestre no de baixo, casas no meio
+	terco := g.Size / 3
+	g.Entrada = sortearPonto(rng, 1, terco, 1, g.Size-2)
+	g.GrandeMestre = sortearPonto(rng, g.Size-1-terco, g.Size-2, 1, g.Size-2)
+	ocupados := []Point{g.Entrada, g.GrandeMestre}
+	afastamento := float64(g.Size) / (math.Sqrt(float64(p.Casas)) + 1)
+	for i := 0; i < p.Casas; i++ {
+		posicao := g.sortearAfastado(rng, ocupados, afastamento)
+		ocupados = append(ocupados, posicao)
+		casa := CASAS_PADRAO[i]
+		casa.Posicao = posicao
+		g.Casas = append(g.Casas, casa)
+	}
+
+	g.ligarMarcos(rng, p.Sinuosidade)
+	if err := g.normalizar(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// gerarTerreno cria o mapa em volta dos marcos já posicionados.
+func (g *Game) gerarTerreno(semente int64, densidade, sinuosidade float64) {
+	rng := rand.New(rand.NewSource(semente))
+	g.espalharMontanhas(rng, densidade)
+	g.ligarMarcos(rng, sinuosidade)
+}
+
+// espalharMontanhas cria o mapa com montanhas onde o ruído fica abaixo do
+// quantil da densidade e terreno de corredor no resto.
+func (g *Game) espalharMontanhas(rng *rand.Rand, densidade float64) {
+	ruido := ruidoValor(rng, g.Size)
+	limiar := quantil(ruido, densidade)
+	g.Mapa = make([][]int, g.Size)
+	for x := range g.Mapa {
+		g.Mapa[x] = make([]int, g.Size)
+		for y := range g.Mapa[x] {
+			if densidade > 0 && ruido[x][y] <= limiar {
+				g.Mapa[x][y] = MONTANHOSO
+			} else {
+				g.Mapa[x][y] = g.terrenoCorredor(rng)
+			}
+		}
+	}
+}
+
+// ligarMarcos abre corredores entre os marcos na ordem zodiacal e alguns
+// atalhos entre marcos quaisquer, e deixa cada marco sobre PLANO, cercado de
+// terreno transitável. Marcos fora do mapa ficam de fora (a validação os
+// aponta depois).
+func (g *Game) ligarMarcos(rng *rand.Rand, sinuosidade float64) {
+	var rota []Point
+	for _, marco := range g.marcos() {
+		if g.posicaoValida(marco) {
+			rota = append(rota, marco)
+		}
+	}
+	if len(rota) == 0 {
+		return
+	}
+
+	for i := 1; i < len(rota); i++ {
+		g.abrirCorredor(rng, rota[i-1], rota[i], sinuosidade)
+	}
+	for i := 0; i < len(g.Casas)/3; i++ {
+		a, b := rota[rng.Intn(len(rota))], rota[rng.Intn(len(rota))]
+		g.abrirCorredor(rng, a, b, sinuosidade)
+	}
+
+	// Na ordem de sorteio: entrada, grande mestre e casas
+	marcos := g.marcos()
+	marcos = append([]Point{marcos[0], marcos[len(marcos)-1]}, marcos[1:len(marcos)-1]...)
+	for _, marco := range marcos {
+		if !g.posicaoValida(marco) {
+			continue
+		}
+		for dx := -1; dx <= 1; dx++ {
+			for dy := -1; dy <= 1; dy++ {
+				vizinho := Point{marco.X + dx, marco.Y + dy}
+				if g.posicaoValida(vizinho) && g.Mapa[vizinho.X][vizinho.Y] == MONTANHOSO {
+					g.Mapa[vizinho.X][vizinho.Y] = g.terrenoCorredor(rng)
+				}
+			}
+		}
+		g.Mapa[marco.X][marco.Y] = PLANO
+	}
+}
+
+func (g *Game) terrenoCorredor(rng *rand.Rand) int {
+	if rng.Float64() < 0.3 {
+		return ROCHOSO
+	}
+	return PLANO
+}
+
+func sortearPonto(rng *rand.Rand, xMin, xMax, yMin, yMax int) Point {
+	return Point{xMin + rng.Intn(xMax-xMin+1), yMin + rng.Intn(yMax-yMin+1)}
+}
+
+// sortearAfastado sorteia uma célula longe dos marcos já colocados, reduzindo
+// o afastamento exigido quando não acha lugar.
+func (g *Game) sortearAfastado(rng *rand.Rand, ocupados []Point, afastamento float64) Point {
+	for {
+		for tentativa := 0; tentativa < TENTATIVAS_POSICAO; tentativa++ {
+			p := sortearPonto(rng, 1, g.Size-2, 1, g.Size-2)
+			livre := true
+			for _, outro := range ocupados {
+				if p == outro || math.Hypot(float64(p.X-outro.X), float64(p.Y-outro.Y)) < afastamento {
+					livre = false
+					break
+				}
+			}
+			if livre {
+				return p
+			}
+		}
+		afastamento *= 0.8
+	}
+}
+
+// abrirCorredor caminha de origem até destino trocando montanhas por terreno
+// de corredor. A cada passo, com chance sinuosidade, anda numa direção
+// qualquer; senão, anda rumo ao destino.
+func (g *Game) abrirCorredor(rng *rand.Rand, origem, destino Point, sinuosidade float64) {
+	direcoes := [4]Point{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+	// Depois de tantos passos o corredor segue reto, para não vagar sem fim
+	limite := 4 * g.Size * g.Size
+	p := origem
+	for passo := 0; p != destino; passo++ {
+		var d Point
+		if passo < limite && rng.Float64() < sinuosidade {
+			d = direcoes[rng.Intn(len(direcoes))]
+		} else {
+			dx, dy := destino.X-p.X, destino.Y-p.Y
+			// Escolhe o eixo na proporção da distância que falta em cada um
+			if rng.Intn(abs(dx)+abs(dy)) < abs(dx) {
+				d = Point{sinal(dx), 0}
+			} else {
+				d = Point{0, sinal(dy)}
+			}
+		}
+		proximo := Point{p.X + d.X, p.Y + d.Y}
+		if !g.posicaoValida(proximo) {
+			continue
+		}
+		p = proximo
+		if g.Mapa[p.X][p.Y] == MONTANHOSO {
+			g.Mapa[p.X][p.Y] = g.terrenoCorredor(rng)
+		}
+	}
+}
+
+// ruidoValor soma duas oitavas de ruído de valor com interpolação suave.
+func ruidoValor(rng *rand.Rand, tamanho int) [][]float64 {
+	ruido := make([][]float64, tamanho)
+	for x := range ruido {
+		ruido[x] = make([]float64, tamanho)
+	}
+	for _, oitava := range []struct {
+		celula int
+		peso   float64
+	}{{8, 1}, {3, 0.4}} {
+		pontos := tamanho/oitava.celula + 2
+		grade := make([][]float64, pontos)
+		for i := range grade {
+			grade[i] = make([]float64, pontos)
+			for j := range grade[i] {
+				grade[i][j] = rng.Float64()
+			}
+		}
+		for x := 0; x < tamanho; x++ {
+			for y := 0; y < tamanho; y++ {
+				gx, gy := float64(x)/float64(oitava.celula), float64(y)/float64(oitava.celula)
+				i, j := int(gx), int(gy)
+				tx, ty := suavizar(gx-float64(i)), suavizar(gy-float64(j))
+				topo := grade[i][j]*(1-ty) + grade[i][j+1]*ty
+				base := grade[i+1][j]*(1-ty) + grade[i+1][j+1]*ty
+				ruido[x][y] += oitava.peso * (topo*(1-tx) + base*tx)
+			}
+		}
+	}
+	return ruido
+}
+
+func suavizar(t float64) float64 {
+	return t * t * (3 - 2*t)
+}
+
+// quantil devolve o valor abaixo do qual fica a fração dada do ruído.
+func quantil(ruido [][]float64, fracao float64) float64 {
+	valores := make([]float64, 0, len(ruido)*len(ruido))
+	for _, linha := range ruido {
+		valores = append(valores, linha...)
+	}
+	sort.Float64s(valores)
+	i := int(fracao * float64(len(valores)-1))
+	return valores[i]
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func sinal(n int) int {
+	switch {
+	case n > 0:
+		return 1
+	case n < 0:
+		return -1
+	}
+	return 0
+}
+
+// ParametrosDaRequisicao lê seed, tamanho, casas, densidade e sinuosidade;
+// os ausentes ficam com PARAMETROS_PADRAO.
+func ParametrosDaRequisicao(r *http.Request) (ParametrosGeracao, error) {
+	query := r.URL.Query()
+	p := PARAMETROS_PADRAO
+	var erros ErrosValidacao
+
+	inteiro := func(nome string, destino *int) {
+		if texto := query.Get(nome); texto != "" {
+			valor, err := strconv.Atoi(texto)
+			if err != nil {
+				erros.adicionar(nome, "número inválido %q", texto)
+			}
+			*destino = valor
+		}
+	}
+	decimal := func(nome string, destino *float64) {
+		if texto := query.Get(nome); texto != "" {
+			valor, err := strconv.ParseFloat(texto, 64)
+			if err != nil {
+				erros.adicionar(nome, "número inválido %q", texto)
+			}
+			*destino = valor
+		}
+	}
+
+	semente, err := strconv.ParseInt(query.Get("seed"), 10, 64)
+	if err != nil {
+		erros.adicionar("seed", "número inválido %q", query.Get("seed"))
+	}
+	p.Semente = semente
+	inteiro("tamanho", &p.Tamanho)
+	inteiro("casas", &p.Casas)
+	decimal("densidade", &p.Densidade)
+	decimal("sinuosidade", &p.Sinuosidade)
+
+	if len(erros) > 0 {
+		return p, erros
+	}
+	return p, p.Validar()
+}
@@ -0,0 +1,359 @@
+package game
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"math"
+	"math/bits"
+	"time"
+	"unsafe"
+)
+
+// ---------------- Matriz de distâncias e Held-Karp ----------------
+// Os marcos são a Entrada, as casas (na ordem de g.Casas) e o Grande Mestre.
+// Um Dijkstra por marco dá o custo de caminhada até todos os outros; a ordem
+// das casas sai exata da programação dinâmica de Held-Karp sobre subconjuntos
+// e o caminho concreto é costurado com as árvores de caminhos mínimos.
+//
+// Com rastro, cada célula fechada por um Dijkstra e cada estado (casas, última
+// casa) expandido pela programação dinâmica vira um PassoRastro, com G = F =
+// caminhada acumulada e H = 0.
+
+const (
+	ALGORITMO_HELD_KARP = "held_karp"
+
+	// A tabela tem 2^n·n entradas: 18 casas ocupam cerca de 24 MB
+	MAXIMO_CASAS_HELD_KARP = 18
+
+	SEM_CAMINHO = math.MaxInt32
+)
+
+// arvoreCaminhos guarda o custo mínimo da origem até cada célula e o
+// antecessor de cada célula no caminho mínimo (-1 na origem ou inalcançável).
+type arvoreCaminhos struct {
+	origem int
+	custo  []int
+	pai    []int32
+}
+
+type itemDistancia struct {
+	celula int
+	custo  int
+}
+
+type filaDistancias []itemDistancia
+
+func (f filaDistancias) Len() int            { return len(f) }
+func (f filaDistancias) Less(i, j int) bool  { return f[i].custo < f[j].custo }
+func (f filaDistancias) Swap(i, j int)       { f[i], f[j] = f[j], f[i] }
+func (f *filaDistancias) Push(x interface{}) { *f = append(*f, x.(itemDistancia)) }
+func (f *filaDistancias) Pop() interface{} {
+	antiga := *f
+	item := antiga[len(antiga)-1]
+	*f = antiga[:len(antiga)-1]
+	return item
+}
+
+// caminhosMinimos roda Dijkstra a partir da origem cobrando o custo de cada
+// passo (custoPasso). Casas para as quais atravessa devolve false
+// são alcançadas mas não servem de passagem (na ordem zodiacal, as casas
+// futuras). Se os limites se esgotarem, devolve o motivo (ABORTADA_*) e a
+// árvore incompleta.
+func (g *Game) caminhosMinimos(gr grade, origem Point, atravessa func(casaID int) bool, limites orcamento, estatisticas *Estatisticas) (arvoreCaminhos, string) {
+	celulas := gr.tamanho * gr.tamanho
+	arvore := arvoreCaminhos{
+		origem: gr.celula(origem),
+		custo:  make([]int, celulas),
+		pai:    make([]int32, celulas),
+	}
+	for i := range arvore.custo {
+		arvore.custo[i] = SEM_CAMINHO
+		arvore.pai[i] = -1
+	}
+	arvore.custo[arvore.origem] = 0
+
+	fila := &filaDistancias{{celula: arvore.origem}}
+	vizinhos := make([]Point, 0, 8)
+	for fila.Len() > 0 {
+		if motivo := limites.esgotado(estatisticas.NosExpandidos); motivo != "" {
+			return arvore, motivo
+		}
+		atual := heap.Pop(fila).(itemDistancia)
+		if atual.custo > arvore.custo[atual.celula] {
+			estatisticas.EstadosDescartados++
+			continue
+		}
+		estatisticas.NosExpandidos++
+		p := Point{atual.celula / gr.tamanho, atual.celula % gr.tamanho}
+		limites.registrar(PassoRastro{X: p.X, Y: p.Y, G: atual.custo, F: atual.custo, Fronteira: fila.Len()})
+		if casaID := gr.casa[atual.celula]; casaID >= 0 && atual.celula != arvore.origem && !atravessa(casaID) {
+			continue
+		}
+
+		vizinhos = g.vizinhosEm(p, vizinhos[:0])
+		for _, vizinho := range vizinhos {
+			celula := gr.celula(vizinho)
+			custo := atual.custo + gr.custoPasso(p, vizinho, celula)
+			if custo < arvore.custo[celula] {
+				arvore.custo[celula] = custo
+				arvore.pai[celula] = int32(atual.celula)
+				heap.Push(fila, itemDistancia{celula: celula, custo: custo})
+				estatisticas.NosGerados++
+			}
+		}
+		if fila.Len() > estatisticas.PicoFronteira {
+			estatisticas.PicoFronteira = fila.Len()
+		}
+	}
+	return arvore, ""
+}
+
+// caminhoAte reconstrói o caminho da origem da árvore até p, inclusive.
+func (a arvoreCaminhos) caminhoAte(gr grade, p Point) []Point {
+	var caminho []Point
+	for celula := gr.celula(p); celula >= 0; celula = int(a.pai[celula]) {
+		caminho = append(caminho, Point{celula / gr.tamanho, celula % gr.tamanho})
+	}
+	for i, j := 0, len(caminho)-1; i < j; i, j = i+1, j-1 {
+		caminho[i], caminho[j] = caminho[j], caminho[i]
+	}
+	return caminho
+}
+
+func (a arvoreCaminhos) bytes() int64 {
+	return int64(len(a.custo))*int64(unsafe.Sizeof(0)) + int64(len(a.pai))*4
+}
+
+func todasCasas(int) bool { return true }
+
+// marcos devolve Entrada, casas e Grande Mestre, nessa ordem.
+func (g *Game) marcos() []Point {
+	marcos := make([]Point, 0, len(g.Casas)+2)
+	marcos = append(marcos, g.Entrada)
+	for _, casa := range g.Casas {
+		marcos = append(marcos, casa.Posicao)
+	}
+	return append(marcos, g.GrandeMestre)
+}
+
+// MatrizDistancias devolve o custo mínimo de caminhada entre cada par de
+// marcos (Entrada, casas na ordem de g.Casas, Grande Mestre), sem contar as
+// batalhas. O custo de a para b inclui a célula b e não a; -1 indica que b
+// não é alcançável a partir de a.
+func (g *Game) MatrizDistancias() [][]int {
+	var estatisticas Estatisticas
+	_, matriz, _ := g.distanciasEntreMarcos(g.prepararGrade(), todasCasas, novoOrcamento(context.Background(), OpcoesBusca{}), &estatisticas)
+	for _, linha := range matriz {
+		for j, custo := range linha {
+			if custo == SEM_CAMINHO {
+				linha[j] = -1
+			}
+		}
+	}
+	return matriz
+}
+
+func (g *Game) distanciasEntreMarcos(gr grade, atravessa func(casaID int) bool, limites orcamento, estatisticas *Estatisticas) ([]arvoreCaminhos, [][]int, string) {
+	marcos := g.marcos()
+	arvores := make([]arvoreCaminhos, len(marcos))
+	matriz := make([][]int, len(marcos))
+	for i, origem := range marcos {
+		var motivo string
+		if arvores[i], motivo = g.caminhosMinimos(gr, origem, atravessa, limites, estatisticas); motivo != "" {
+			return nil, nil, motivo
+		}
+		matriz[i] = make([]int, len(marcos))
+		for j, destino := range marcos {
+			matriz[i][j] = arvores[i].custo[gr.celula(destino)]
+		}
+	}
+	return arvores, matriz, ""
+}
+
+// tabelaHeldKarp guarda, para cada subconjunto de casas e cada casa final
+// desse subconjunto, a menor caminhada saindo da Entrada.
+type tabelaHeldKarp struct {
+	n        int
+	custo    []int32
+	anterior []int8
+}
+
+// montarHeldKarp preenche a tabela por programação dinâmica sobre
+// subconjuntos; se os limites se esgotarem, devolve o motivo (ABORTADA_*).
+func (g *Game) montarHeldKarp(matriz [][]int, limites orcamento, estatisticas *Estatisticas) (tabelaHeldKarp, string) {
+	n := len(matriz) - 2
+	t := tabelaHeldKarp{n: n}
+	if n == 0 {
+		return t, ""
+	}
+
+	// custo[mascara*n+j]: menor caminhada saindo da Entrada, passando pelas
+	// casas de mascara e terminando na casa j (que pertence a mascara)
+	total := 1 << n
+	t.custo = make([]int32, total*n)
+	t.anterior = make([]int8, total*n)
+	for i := range t.custo {
+		t.custo[i] = SEM_CAMINHO
+	}
+	for j := 0; j < n; j++ {
+		t.custo[(1<<j)*n+j] = int32(matriz[0][j+1])
+		t.anterior[(1<<j)*n+j] = -1
+	}
+
+	for mascara := 1; mascara < total; mascara++ {
+		if motivo := limites.esgotado(estatisticas.NosExpandidos); motivo != "" {
+			return t, motivo
+		}
+		for j := 0; j < n; j++ {
+			atual := t.custo[mascara*n+j]
+			if mascara&(1<<j) == 0 || atual == SEM_CAMINHO {
+				continue
+			}
+			estatisticas.NosExpandidos++
+			casa := g.Casas[j].Posicao
+			limites.registrar(PassoRastro{X: casa.X, Y: casa.Y, G: int(atual), F: int(atual), Casas: uint64(mascara)})
+			for k := 0; k < n; k++ {
+				if mascara&(1<<k) != 0 || matriz[j+1][k+1] == SEM_CAMINHO {
+					continue
+				}
+				proxima := mascara | 1<<k
+				novo := atual + int32(matriz[j+1][k+1])
+				if novo < t.custo[proxima*n+k] {
+					t.custo[proxima*n+k] = novo
+					t.anterior[proxima*n+k] = int8(j)
+					estatisticas.NosGerados++
+				}
+			}
+		}
+	}
+	estatisticas.MemoriaAproximada += int64(len(t.custo))*4 + int64(len(t.anterior))
+	return t, ""
+}
+
+// rota devolve a ordem de visita das casas de mascara que minimiza a
+// caminhada da Entrada ao Grande Mestre e esse custo, ou SEM_CAMINHO.
+func (t tabelaHeldKarp) rota(matriz [][]int, mascara int) ([]int, int) {
+	n, mestre := t.n, t.n+1
+	if mascara == 0 {
+		return nil, matriz[0][mestre]
+	}
+
+	melhor, ultima := SEM_CAMINHO, -1
+	for j := 0; j < n; j++ {
+		if mascara&(1<<j) == 0 || t.custo[mascara*n+j] == SEM_CAMINHO || matriz[j+1][mestre] == SEM_CAMINHO {
+			continue
+		}
+		if c := int(t.custo[mascara*n+j]) + matriz[j+1][mestre]; c < melhor {
+			melhor, ultima = c, j
+		}
+	}
+	if ultima < 0 {
+		return nil, SEM_CAMINHO
+	}
+
+	ordem := make([]int, bits.OnesCount(uint(mascara)))
+	for j, i := ultima, len(ordem)-1; j >= 0; i-- {
+		ordem[i] = j
+		j, mascara = int(t.anterior[mascara*n+j]), mascara&^(1<<j)
+	}
+	return ordem, melhor
+}
+
+// costurarRota junta os caminhos mínimos entre marcos consecutivos da rota
+// Entrada → casas de ordem → Grande Mestre.
+func (g *Game) costurarRota(gr grade, arvores []arvoreCaminhos, ordem []int, estatisticas *Estatisticas) []Point {
+	caminho := []Point{g.Entrada}
+	origem := 0
+	for _, casaID := range ordem {
+		caminho = append(caminho, arvores[origem].caminhoAte(gr, g.Casas[casaID].Posicao)[1:]...)
+		origem = casaID + 1
+	}
+	caminho = append(caminho, arvores[origem].caminhoAte(gr, g.GrandeMestre)[1:]...)
+	for _, arvore := range arvores {
+		estatisticas.MemoriaAproximada += arvore.bytes()
+	}
+	return caminho
+}
+
+// HeldKarp resolve o jogo sobre a matriz de distâncias entre marcos. No modo
+// livre a ordem das casas é exata por programação dinâmica; na ordem zodiacal
+// a ordem é fixa e cada trecho é um caminho mínimo que evita as casas futuras.
+type HeldKarp struct{}
+
+func (HeldKarp) Nome() string { return ALGORITMO_HELD_KARP }
+
+func (HeldKarp) Resolver(ctx context.Context, g *Game, opcoes OpcoesBusca) ResultadoBusca {
+	if opcoes.Objetivo == OBJETIVO_MAXIMO_CASAS {
+		return g.maximizarCasas(ctx, opcoes)
+	}
+
+	inicio := time.Now()
+	limites := novoOrcamento(ctx, opcoes)
+	var estatisticas Estatisticas
+
+	falha := func(motivo string) ResultadoBusca {
+		return resultadoFalha(opcoes, motivo, estatisticas, inicio)
+	}
+	// Sem rota montada não há caminho parcial a devolver
+	abortada := func(motivo string) ResultadoBusca {
+		resultado := falha(descreverInterrupcao(motivo, estatisticas.NosExpandidos))
+		resultado.Abortada = motivo
+		return resultado
+	}
+
+	ordenado := opcoes.Modo == MODO_ORDEM_ZODIACAL
+	if !ordenado && len(g.Casas) > MAXIMO_CASAS_HELD_KARP {
+		return falha(fmt.Sprintf("held_karp aceita no máximo %d casas no modo livre, recebidas %d", MAXIMO_CASAS_HELD_KARP, len(g.Casas)))
+	}
+
+	plano, err := g.planejarBatalhas()
+	if err != nil {
+		return falha(err.Error())
+	}
+	gr := g.prepararGrade()
+
+	var caminho []Point
+	if ordenado {
+		// Cada trecho vai de um marco ao seguinte; no trecho até a casa i as
+		// casas seguintes ainda não podem ser atravessadas
+		marcos := g.marcos()
+		caminho = []Point{g.Entrada}
+		acumulado := 0
+		for i := 0; i+1 < len(marcos); i++ {
+			liberadas := i + 1
+			trecho := limites.noTrecho(mascaraCompleta(i), acumulado)
+			arvore, motivo := g.caminhosMinimos(gr, marcos[i], func(casaID int) bool { return casaID < liberadas }, trecho, &estatisticas)
+			if motivo != "" {
+				return abortada(motivo)
+			}
+			if arvore.custo[gr.celula(marcos[i+1])] == SEM_CAMINHO {
+				return falha(fmt.Sprintf("nenhum caminho de %v até %v na ordem zodiacal", marcos[i], marcos[i+1]))
+			}
+			caminho = append(caminho, arvore.caminhoAte(gr, marcos[i+1])[1:]...)
+			estatisticas.MemoriaAproximada += arvore.bytes()
+			acumulado += arvore.custo[gr.celula(marcos[i+1])]
+			if i < len(g.Casas) {
+				acumulado += minutosBatalha(plano.Batalhas[i].Tempo)
+			}
+		}
+	} else {
+		arvores, matriz, motivo := g.distanciasEntreMarcos(gr, todasCasas, limites, &estatisticas)
+		if motivo != "" {
+			return abortada(motivo)
+		}
+		tabela, motivo := g.montarHeldKarp(matriz, limites, &estatisticas)
+		if motivo != "" {
+			return abortada(motivo)
+		}
+		ordem, custo := tabela.rota(matriz, int(mascaraCompleta(len(g.Casas))))
+		if custo == SEM_CAMINHO {
+			return falha("nenhum caminho passa por todas as casas até o Grande Mestre")
+		}
+		caminho = g.costurarRota(gr, arvores, ordem, &estatisticas)
+	}
+
+	resultado := g.resultadoDoCaminho(opcoes, caminho, plano, estatisticas, inicio)
+	resultado.OtimoGarantido = plano.Otima
+	return resultado
+}
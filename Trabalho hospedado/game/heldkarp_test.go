@@ -0,0 +1,46 @@
+package game
+
+import (
+	"fmt"
+	"testing"
+)
+
+// O Held-Karp resolve o passeio sobre as distâncias entre marcos; o custo
+// tem de bater com o do A* (heurística admissível) e o do Dijkstra, que
+// buscam no espaço de estados completo.
+func TestHeldKarpMesmoCustoQueAEstrela(t *testing.T) {
+	type caso struct {
+		nome string
+		jogo *Game
+		// O Dijkstra no modo livre do jogo padrão leva segundos
+		dijkstra bool
+	}
+	jogos := []caso{{"padrao", NovoJogo(), false}}
+	for _, semente := range []int64{1, 7, 23, 42} {
+		g, err := GerarSantuario(ParametrosGeracao{Semente: semente, Tamanho: 24, Casas: 4, Densidade: 0.5, Sinuosidade: 0.35})
+		if err != nil {
+			t.Fatal(err)
+		}
+		jogos = append(jogos, caso{fmt.Sprintf("semente_%d", semente), g, true})
+	}
+
+	for _, caso := range jogos {
+		for _, modo := range []string{MODO_LIVRE, MODO_ORDEM_ZODIACAL} {
+			t.Run(caso.nome+"/"+modo, func(t *testing.T) {
+				heldKarp := caso.jogo.Buscar(OpcoesBusca{Modo: modo, Algoritmo: ALGORITMO_HELD_KARP})
+				if !heldKarp.Sucesso {
+					t.Fatalf("held_karp falhou: %s", heldKarp.Motivo)
+				}
+				referencias := []OpcoesBusca{{Modo: modo, Algoritmo: ALGORITMO_ASTAR, Heuristica: HEURISTICA_MST_BATALHAS}}
+				if caso.dijkstra || modo == MODO_ORDEM_ZODIACAL {
+					referencias = append(referencias, OpcoesBusca{Modo: modo, Algoritmo: ALGORITMO_DIJKSTRA})
+				}
+				for _, opcoes := range referencias {
+					if r := caso.jogo.Buscar(opcoes); r.CustoTotal != heldKarp.CustoTotal {
+						t.Errorf("held_karp custou %d, %s %d", heldKarp.CustoTotal, opcoes.Algoritmo, r.CustoTotal)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package game
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+)
+
+// ---------------- Heurísticas ----------------
+// Uma heurística admissível nunca superestima o custo restante; com ela o A*
+// devolve o caminho ótimo. As consistentes também garantem que um estado
+// expandido nunca precisa ser reaberto.
+
+const (
+	HEURISTICA_ZERO         = "zero"
+	HEURISTICA_MANHATTAN    = "manhattan"
+	HEURISTICA_MST          = "mst"
+	HEURISTICA_BATALHAS     = "batalhas"
+	HEURISTICA_MST_BATALHAS = "mst_batalhas"
+	HEURISTICA_CLASSICA     = "classica"
+
+	// A clássica continua como padrão por ser a mais rápida no mapa de 42x42;
+	// para ótimo garantido escolha uma admissível
+	HEURISTICA_PADRAO = HEURISTICA_CLASSICA
+)
+
+type Heuristica interface {
+	Nome() string
+	Admissivel() bool
+	Consistente() bool
+	// Preparar faz os cálculos que dependem só do jogo e devolve a estimativa
+	// do custo restante a partir de uma célula com as casas da máscara já
+	// conquistadas.
+	Preparar(c contextoHeuristica) func(p Point, mascara uint64) int
+}
+
+type contextoHeuristica struct {
+	g        *Game
+	grade    grade
+	tempos   []int
+	ordenado bool
+}
+
+var HEURISTICAS = map[string]Heuristica{
+	HEURISTICA_ZERO:         heuristicaZero{},
+	HEURISTICA_MANHATTAN:    heuristicaManhattan{},
+	HEURISTICA_MST:          heuristicaMST{},
+	HEURISTICA_BATALHAS:     heuristicaBatalhas{},
+	HEURISTICA_MST_BATALHAS: heuristicaSoma{HEURISTICA_MST_BATALHAS, []Heuristica{heuristicaMST{}, heuristicaBatalhas{}}},
+	HEURISTICA_CLASSICA:     heuristicaClassica{},
+}
+
+func ObterHeuristica(nome string) (Heuristica, error) {
+	if nome == "" {
+		nome = HEURISTICA_PADRAO
+	}
+	h, existe := HEURISTICAS[nome]
+	if !existe {
+		return nil, fmt.Errorf("heurística desconhecida %q (disponíveis: %v)", nome, NomesHeuristicas())
+	}
+	return h, nil
+}
+
+func NomesHeuristicas() []string {
+	nomes := make([]string, 0, len(HEURISTICAS))
+	for nome := range HEURISTICAS {
+		nomes = append(nomes, nome)
+	}
+	sort.Strings(nomes)
+	return nomes
+}
+
+// custoMinimo é o menor custo de entrar em qualquer célula: multiplicado por
+// uma distância em passos, nunca superestima a caminhada
+func (c contextoHeuristica) custoMinimo() int {
+	minimo := -1
+	for _, custo := range c.grade.custo {
+		if custo >= 0 && (minimo < 0 || custo < minimo) {
+			minimo = custo
+		}
+	}
+	if minimo < 0 {
+		return 0
+	}
+	return minimo
+}
+
+// distancia é o limite inferior da caminhada entre dois pontos no modelo de
+// movimento do jogo: Manhattan, octil ou hexagonal vezes o custo mínimo
+func (c contextoHeuristica) distancia() func(a, b Point) int {
+	return c.g.Movimento.limiteCaminhada(c.custoMinimo())
+}
+
+type heuristicaZero struct{}
+
+func (heuristicaZero) Nome() string      { return HEURISTICA_ZERO }
+func (heuristicaZero) Admissivel() bool  { return true }
+func (heuristicaZero) Consistente() bool { return true }
+
+func (heuristicaZero) Preparar(contextoHeuristica) func(Point, uint64) int {
+	return func(Point, uint64) int { return 0 }
+}
+
+// heuristicaManhattan ignora as casas e mede só a distância até o Grande
+// Mestre; fora do movimento ortogonal a distância é a octil ou a hexagonal
+type heuristicaManhattan struct{}
+
+func (heuristicaManhattan) Nome() string      { return HEURISTICA_MANHATTAN }
+func (heuristicaManhattan) Admissivel() bool  { return true }
+func (heuristicaManhattan) Consistente() bool { return true }
+
+func (heuristicaManhattan) Preparar(c contextoHeuristica) func(Point, uint64) int {
+	distancia := c.distancia()
+	destino := c.g.GrandeMestre
+	return func(p Point, _ uint64) int {
+		return distancia(p, destino)
+	}
+}
+
+// heuristicaMST limita a caminhada restante: qualquer rota que parte de p,
+// passa pelas casas pendentes e termina no Grande Mestre custa ao menos a
+// ligação de p ao conjunto mais a árvore geradora mínima desse conjunto. Na
+// ordem zodiacal a sequência é conhecida e a cadeia de distâncias é usada.
+type heuristicaMST struct{}
+
+func (heuristicaMST) Nome() string      { return HEURISTICA_MST }
+func (heuristicaMST) Admissivel() bool  { return true }
+func (heuristicaMST) Consistente() bool { return true }
+
+func (heuristicaMST) Preparar(c contextoHeuristica) func(Point, uint64) int {
+	distancia := c.distancia()
+	casas := c.g.Casas
+	destino := c.g.GrandeMestre
+
+	if c.ordenado {
+		// cadeia[i]: distância de casas[i] até o Grande Mestre passando pelas seguintes
+		cadeia := make([]int, len(casas)+1)
+		for i := len(casas) - 1; i >= 0; i-- {
+			proximo := destino
+			if i+1 < len(casas) {
+				proximo = casas[i+1].Posicao
+			}
+			cadeia[i] = cadeia[i+1] + distancia(casas[i].Posicao, proximo)
+		}
+		return func(p Point, mascara uint64) int {
+			i := bits.OnesCount64(mascara)
+			if i == len(casas) {
+				return distancia(p, destino)
+			}
+			return distancia(p, casas[i].Posicao) + cadeia[i]
+		}
+	}
+
+	type pendentes struct {
+		pontos []Point
+		arvore int
+	}
+	porMascara := make(map[uint64]pendentes)
+
+	return func(p Point, mascara uint64) int {
+		conjunto, existe := porMascara[mascara]
+		if !existe {
+			conjunto.pontos = []Point{destino}
+			for i, casa := range casas {
+				if mascara&(1<<i) == 0 {
+					conjunto.pontos = append(conjunto.pontos, casa.Posicao)
+				}
+			}
+			conjunto.arvore = arvoreGeradoraMinima(conjunto.pontos, distancia)
+			porMascara[mascara] = conjunto
+		}
+
+		ligacao := distancia(p, destino)
+		for _, q := range conjunto.pontos[1:] {
+			if d := distancia(p, q); d < ligacao {
+				ligacao = d
+			}
+		}
+		return ligacao + conjunto.arvore
+	}
+}
+
+// arvoreGeradoraMinima (Prim) com a distância dada
+func arvoreGeradoraMinima(pontos []Point, distancia func(a, b Point) int) int {
+	if len(pontos) < 2 {
+		return 0
+	}
+
+	naArvore := make([]bool, len(pontos))
+	distanciaArvore := make([]int, len(pontos))
+	for i := range distanciaArvore {
+		distanciaArvore[i] = distancia(pontos[0], pontos[i])
+	}
+	naArvore[0] = true
+
+	total := 0
+	for k := 1; k < len(pontos); k++ {
+		escolhido := -1
+		for i := range pontos {
+			if !naArvore[i] && (escolhido < 0 || distanciaArvore[i] < distanciaArvore[escolhido]) {
+				escolhido = i
+			}
+		}
+		naArvore[escolhido] = true
+		total += distanciaArvore[escolhido]
+		for i := range pontos {
+			if d := distancia(pontos[escolhido], pontos[i]); !naArvore[i] && d < distanciaArvore[i] {
+				distanciaArvore[i] = d
+			}
+		}
+	}
+	return total
+}
+
+// heuristicaBatalhas soma o tempo das batalhas ainda não travadas, calculado
+// de antemão pelo plano de batalhas
+type heuristicaBatalhas struct{}
+
+func (heuristicaBatalhas) Nome() string      { return HEURISTICA_BATALHAS }
+func (heuristicaBatalhas) Admissivel() bool  { return true }
+func (heuristicaBatalhas) Consistente() bool { return true }
+
+func (heuristicaBatalhas) Preparar(c contextoHeuristica) func(Point, uint64) int {
+	tempos := c.tempos
+	return func(_ Point, mascara uint64) int {
+		restante := 0
+		for i, tempo := range tempos {
+			if mascara&(1<<i) == 0 {
+				restante += tempo
+			}
+		}
+		return restante
+	}
+}
+
+// heuristicaSoma soma heurísticas que limitam partes disjuntas do custo
+// (caminhada e batalhas); a soma preserva admissibilidade e consistência
+type heuristicaSoma struct {
+	nome   string
+	partes []Heuristica
+}
+
+func (h heuristicaSoma) Nome() string { return h.nome }
+
+func (h heuristicaSoma) Admissivel() bool {
+	for _, parte := range h.partes {
+		if !parte.Admissivel() {
+			return false
+		}
+	}
+	return true
+}
+
+func (h heuristicaSoma) Consistente() bool {
+	for _, parte := range h.partes {
+		if !parte.Consistente() {
+			return false
+		}
+	}
+	return true
+}
+
+func (h heuristicaSoma) Preparar(c contextoHeuristica) func(Point, uint64) int {
+	funcoes := make([]func(Point, uint64) int, len(h.partes))
+	for i, parte := range h.partes {
+		funcoes[i] = parte.Preparar(c)
+	}
+	return func(p Point, mascara uint64) int {
+		total := 0
+		for _, f := range funcoes {
+			total += f(p, mascara)
+		}
+		return total
+	}
+}
+
+// heuristicaClassica é a estimativa original: passos até o Grande Mestre
+// (Manhattan no movimento ortogonal) mais 50 por casa pendente. Batalhas
+// podem custar menos que 50 e a volta pelas casas é ignorada, então ela não é
+// admissível.
+type heuristicaClassica struct{}
+
+func (heuristicaClassica) Nome() string      { return HEURISTICA_CLASSICA }
+func (heuristicaClassica) Admissivel() bool  { return false }
+func (heuristicaClassica) Consistente() bool { return false }
+
+func (heuristicaClassica) Preparar(c contextoHeuristica) func(Point, uint64) int {
+	movimento := c.g.Movimento
+	destino := c.g.GrandeMestre
+	casas := len(c.g.Casas)
+	return func(p Point, mascara uint64) int {
+		return movimento.passos(p, destino) + (casas-bits.OnesCount64(mascara))*50
+	}
+}
@@ -0,0 +1,45 @@
+package game
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Com heurística admissível o A* acha o custo do Dijkstra e diz que o ótimo
+// é garantido; com a clássica, que não é admissível, não pode dizer.
+func TestHeuristicasAdmissiveisAchamOOtimo(t *testing.T) {
+	nomes, jogos := []string{"padrao"}, map[string]*Game{"padrao": NovoJogo()}
+	for _, semente := range []int64{3, 11, 29} {
+		g, err := GerarSantuario(ParametrosGeracao{Semente: semente, Tamanho: 20, Casas: 3, Densidade: 0.5, Sinuosidade: 0.35})
+		if err != nil {
+			t.Fatal(err)
+		}
+		nome := fmt.Sprintf("semente_%d", semente)
+		nomes, jogos[nome] = append(nomes, nome), g
+	}
+
+	for _, nome := range nomes {
+		g := jogos[nome]
+		for _, modo := range []string{MODO_LIVRE, MODO_ORDEM_ZODIACAL} {
+			// O Dijkstra no modo livre do jogo padrão leva segundos
+			if nome == "padrao" && modo == MODO_LIVRE {
+				continue
+			}
+			t.Run(nome+"/"+modo, func(t *testing.T) {
+				dijkstra := g.Buscar(OpcoesBusca{Modo: modo, Algoritmo: ALGORITMO_DIJKSTRA})
+				if !dijkstra.Sucesso || !dijkstra.OtimoGarantido {
+					t.Fatalf("dijkstra: sucesso %v, otimo_garantido %v", dijkstra.Sucesso, dijkstra.OtimoGarantido)
+				}
+				for _, heuristica := range []string{HEURISTICA_ZERO, HEURISTICA_MANHATTAN, HEURISTICA_MST, HEURISTICA_BATALHAS, HEURISTICA_MST_BATALHAS} {
+					r := g.Buscar(OpcoesBusca{Modo: modo, Heuristica: heuristica})
+					if r.CustoTotal != dijkstra.CustoTotal || !r.OtimoGarantido {
+						t.Errorf("%s: custo %d (dijkstra %d), otimo_garantido %v", heuristica, r.CustoTotal, dijkstra.CustoTotal, r.OtimoGarantido)
+					}
+				}
+				if r := g.Buscar(OpcoesBusca{Modo: modo, Heuristica: HEURISTICA_CLASSICA}); r.OtimoGarantido {
+					t.Errorf("%s: otimo_garantido verdadeiro com heurística não admissível", HEURISTICA_CLASSICA)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package game
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"image/png"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ---------------- Mapa em imagem PNG ----------------
+// Cada pixel é uma célula: a linha da imagem é o x e a coluna o y, como no
+// mapa em texto. A cor do pixel escolhe o terreno pela Cor do registro de
+// terrenos ou um marco pelas cores da paleta. Cavaleiros e nomes e
+// dificuldades das casas vêm de NovoJogo.
+
+const (
+	TAMANHO_MAXIMO_IMAGEM = 512
+
+	// Pixels com cor desconhecida reportados antes de desistir
+	LIMITE_ERROS_IMAGEM = 20
+)
+
+type PaletaImagem struct {
+	// Terrenos substituem os padrão de mesmo nome ou entram após eles; a Cor
+	// de cada um é a cor dos seus pixels
+	Terrenos     []TipoTerreno `json:"terrenos"`
+	Entrada      string        `json:"entrada"`
+	GrandeMestre string        `json:"grande_mestre"`
+	// Uma cor por casa, na ordem das casas; com uma só cor, todo pixel dela é
+	// uma casa, numerada na ordem de leitura da imagem
+	Casas []string `json:"casas"`
+}
+
+// As mesmas cores dos marcos na interface
+var PALETA_PADRAO = PaletaImagem{
+	Entrada:      "#ff4444",
+	GrandeMestre: "#44ff44",
+	Casas:        []string{"#ffaa00"},
+}
+
+// lerCor aceita #rrggbb e #rgb.
+func lerCor(texto string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(texto, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	valor, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 6 || err != nil || !strings.HasPrefix(texto, "#") {
+		return color.RGBA{}, fmt.Errorf("cor inválida %q (use #rrggbb)", texto)
+	}
+	return color.RGBA{R: uint8(valor >> 16), G: uint8(valor >> 8), B: uint8(valor), A: 0xff}, nil
+}
+
+// significadoCor diz o que um pixel representa: um terreno (índice em
+// Terrenos) ou um marco (valor da camada de sobreposição).
+type significadoCor struct {
+	terreno int
+	marco   int
+}
+
+// LerMapaImagem monta um Game a partir de um PNG quadrado e o valida.
+func LerMapaImagem(r io.Reader, paleta PaletaImagem) (*Game, error) {
+	var erros ErrosValidacao
+	g := &Game{
+		Terrenos:   mesclarTerrenos(TERRENOS_PADRAO, paleta.Terrenos),
+		Cavaleiros: cavaleirosPadrao(),
+	}
+	validarTerrenos(paleta.Terrenos, "paleta.terrenos", &erros)
+	if paleta.Entrada == "" {
+		paleta.Entrada = PALETA_PADRAO.Entrada
+	}
+	if paleta.GrandeMestre == "" {
+		paleta.GrandeMestre = PALETA_PADRAO.GrandeMestre
+	}
+	if len(paleta.Casas) == 0 {
+		paleta.Casas = PALETA_PADRAO.Casas
+	}
+	if len(paleta.Casas) > len(CASAS_PADRAO) {
+		erros.adicionar("paleta.casas", "no máximo %d casas, recebidas %d cores", len(CASAS_PADRAO), len(paleta.Casas))
+	}
+
+	cores := make(map[color.RGBA]significadoCor)
+	registrar := func(campo, texto string, significado significadoCor) {
+		cor, err := lerCor(texto)
+		if err != nil {
+			erros.adicionar(campo, "%v", err)
+			return
+		}
+		if _, repetida := cores[cor]; repetida {
+			erros.adicionar(campo, "cor %s usada duas vezes na paleta", texto)
+			return
+		}
+		cores[cor] = significado
+	}
+	for i, terreno := range g.Terrenos {
+		// Terreno sem cor não aparece na imagem
+		if terreno.Cor != "" {
+			registrar(fmt.Sprintf("paleta.terrenos.%s.cor", terreno.Nome), terreno.Cor, significadoCor{terreno: i})
+		}
+	}
+	registrar("paleta.entrada", paleta.Entrada, significadoCor{terreno: PLANO, marco: ENTRADA})
+	registrar("paleta.grande_mestre", paleta.GrandeMestre, significadoCor{terreno: PLANO, marco: GRANDE_MESTRE})
+	for i, cor := range paleta.Casas {
+		registrar(fmt.Sprintf("paleta.casas[%d]", i), cor, significadoCor{terreno: PLANO, marco: CASA_ZODIACO + i})
+	}
+	if len(erros) > 0 {
+		return nil, erros
+	}
+
+	// As dimensões são conferidas antes de decodificar os pixels
+	conteudo, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	configuracao, err := png.DecodeConfig(bytes.NewReader(conteudo))
+	if err != nil {
+		return nil, ErrosValidacao{{Campo: "imagem", Mensagem: fmt.Sprintf("PNG inválido: %v", err)}}
+	}
+	largura, altura := configuracao.Width, configuracao.Height
+	switch {
+	case largura != altura:
+		erros.adicionar("imagem", "a imagem deve ser quadrada, recebida %dx%d", largura, altura)
+	case largura > TAMANHO_MAXIMO_IMAGEM:
+		erros.adicionar("imagem", "no máximo %dx%d pixels, recebida %dx%d", TAMANHO_MAXIMO_IMAGEM, TAMANHO_MAXIMO_IMAGEM, largura, altura)
+	}
+	if len(erros) > 0 {
+		return nil, erros
+	}
+	img, err := png.Decode(bytes.NewReader(conteudo))
+	if err != nil {
+		return nil, ErrosValidacao{{Campo: "imagem", Mensagem: fmt.Sprintf("PNG inválido: %v", err)}}
+	}
+	limites := img.Bounds()
+
+	g.Size = largura
+	g.Mapa = make([][]int, g.Size)
+	entrada, grandeMestre := false, false
+	casas := make(map[int]Point)
+	umaCorDeCasa := len(paleta.Casas) == 1
+	for x := 0; x < g.Size && len(erros) < LIMITE_ERROS_IMAGEM; x++ {
+		g.Mapa[x] = make([]int, g.Size)
+		for y := 0; y < g.Size && len(erros) < LIMITE_ERROS_IMAGEM; y++ {
+			campo := fmt.Sprintf("imagem[%d][%d]", x, y)
+			pixel := color.NRGBAModel.Convert(img.At(limites.Min.X+y, limites.Min.Y+x)).(color.NRGBA)
+			significado, existe := cores[color.RGBA{R: pixel.R, G: pixel.G, B: pixel.B, A: 0xff}]
+			if !existe || pixel.A != 0xff {
+				erros.adicionar(campo, "cor #%02x%02x%02x (alfa %d) fora da paleta", pixel.R, pixel.G, pixel.B, pixel.A)
+				continue
+			}
+			g.Mapa[x][y] = significado.terreno
+
+			p := Point{x, y}
+			switch marco := significado.marco; {
+			case marco == ENTRADA:
+				if entrada {
+					erros.adicionar(campo, "segunda entrada (a primeira está em %d, %d)", g.Entrada.X, g.Entrada.Y)
+				}
+				g.Entrada, entrada = p, true
+			case marco == GRANDE_MESTRE:
+				if grandeMestre {
+					erros.adicionar(campo, "segundo grande mestre (o primeiro está em %d, %d)", g.GrandeMestre.X, g.GrandeMestre.Y)
+				}
+				g.GrandeMestre, grandeMestre = p, true
+			case marco >= CASA_ZODIACO:
+				i := marco - CASA_ZODIACO
+				if umaCorDeCasa {
+					i = len(casas)
+				}
+				if anterior, repetida := casas[i]; repetida {
+					erros.adicionar(campo, "casa %d repetida (já em %d, %d)", i, anterior.X, anterior.Y)
+				}
+				casas[i] = p
+			}
+		}
+	}
+	if len(erros) > 0 {
+		return nil, erros
+	}
+
+	if !entrada {
+		erros.adicionar("imagem", "nenhum pixel da entrada (%s)", paleta.Entrada)
+	}
+	if !grandeMestre {
+		erros.adicionar("imagem", "nenhum pixel do grande mestre (%s)", paleta.GrandeMestre)
+	}
+	if len(casas) > len(CASAS_PADRAO) {
+		erros.adicionar("imagem", "no máximo %d casas, encontradas %d", len(CASAS_PADRAO), len(casas))
+	}
+	for i := 0; i < len(casas) && i < len(CASAS_PADRAO); i++ {
+		posicao, existe := casas[i]
+		if !existe {
+			erros.adicionar("imagem", "casa %d ausente (cor %s)", i, paleta.Casas[i])
+			continue
+		}
+		casa := CASAS_PADRAO[i]
+		casa.Posicao = posicao
+		g.Casas = append(g.Casas, casa)
+	}
+	if len(erros) > 0 {
+		return nil, erros
+	}
+
+	if err := g.normalizar(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// PaletaDaRequisicao lê a paleta do parâmetro paleta (JSON), se houver.
+func PaletaDaRequisicao(r *http.Request) (PaletaImagem, error) {
+	var paleta PaletaImagem
+	texto := r.URL.Query().Get("paleta")
+	if texto == "" {
+		return paleta, nil
+	}
+	if err := json.Unmarshal([]byte(texto), &paleta); err != nil {
+		return paleta, ErrosValidacao{{Campo: "paleta", Mensagem: fmt.Sprintf("JSON inválido: %v", err)}}
+	}
+	return paleta, nil
+}
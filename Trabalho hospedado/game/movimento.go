@@ -0,0 +1,145 @@
+package game
+
+import "math"
+
+// ---------------- Modelos de movimento ----------------
+// ortogonal: 4 vizinhos. diagonal: 8 vizinhos; o passo diagonal custa o
+// terreno de destino vezes CustoDiagonal, arredondado para cima (no plano,
+// de custo 1, a diagonal √2 custa 2), e Cantos diz quando ele pode cortar a
+// quina de uma célula intransponível. hexagonal: 6 vizinhos em coordenadas
+// axiais, com X como a linha r e Y como a coluna q; os vizinhos de (r, q) são
+// (r±1, q), (r, q±1), (r-1, q+1) e (r+1, q-1).
+
+const (
+	MOVIMENTO_ORTOGONAL = "ortogonal"
+	MOVIMENTO_DIAGONAL  = "diagonal"
+	MOVIMENTO_HEXAGONAL = "hexagonal"
+
+	// Diagonal sempre permitida
+	CANTOS_PERMITIR = "permitir"
+	// Proibida só entre duas células intransponíveis
+	CANTOS_SEM_ESPREMER = "sem_espremer"
+	// Proibida junto a qualquer célula intransponível
+	CANTOS_PROIBIR = "proibir"
+)
+
+type Movimento struct {
+	Tipo string `json:"tipo"`
+	// Só no diagonal: multiplicador do passo diagonal (padrão √2) e regra
+	// das quinas (padrão proibir)
+	CustoDiagonal float64 `json:"custo_diagonal,omitempty"`
+	Cantos        string  `json:"cantos,omitempty"`
+}
+
+var (
+	DIRECOES_ORTOGONAIS = []Point{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+	DIRECOES_DIAGONAIS  = []Point{{-1, -1}, {-1, 1}, {1, -1}, {1, 1}}
+	DIRECOES_HEXAGONAIS = []Point{{-1, 0}, {1, 0}, {0, -1}, {0, 1}, {-1, 1}, {1, -1}}
+)
+
+// normalizar completa os padrões do modelo.
+func (m *Movimento) normalizar() {
+	if m.Tipo == "" {
+		m.Tipo = MOVIMENTO_ORTOGONAL
+	}
+	if m.Tipo != MOVIMENTO_DIAGONAL {
+		return
+	}
+	if m.CustoDiagonal == 0 {
+		m.CustoDiagonal = math.Sqrt2
+	}
+	if m.Cantos == "" {
+		m.Cantos = CANTOS_PROIBIR
+	}
+}
+
+func (m Movimento) validar(campo string, erros *ErrosValidacao) {
+	switch m.Tipo {
+	case "", MOVIMENTO_ORTOGONAL, MOVIMENTO_HEXAGONAL:
+		if m.CustoDiagonal != 0 || m.Cantos != "" {
+			erros.adicionar(campo, "custo_diagonal e cantos valem só no movimento %s", MOVIMENTO_DIAGONAL)
+		}
+	case MOVIMENTO_DIAGONAL:
+		// Abaixo de 1 a diagonal sairia mais barata que o passo reto e a
+		// distância octil deixaria de ser um limite inferior
+		if m.CustoDiagonal != 0 && m.CustoDiagonal < 1 {
+			erros.adicionar(campo+".custo_diagonal", "deve ser ao menos 1, recebido %g", m.CustoDiagonal)
+		}
+		switch m.Cantos {
+		case "", CANTOS_PERMITIR, CANTOS_SEM_ESPREMER, CANTOS_PROIBIR:
+		default:
+			erros.adicionar(campo+".cantos", "regra desconhecida %q (use %s, %s ou %s)", m.Cantos, CANTOS_PERMITIR, CANTOS_SEM_ESPREMER, CANTOS_PROIBIR)
+		}
+	default:
+		erros.adicionar(campo+".tipo", "movimento desconhecido %q (use %s, %s ou %s)", m.Tipo, MOVIMENTO_ORTOGONAL, MOVIMENTO_DIAGONAL, MOVIMENTO_HEXAGONAL)
+	}
+}
+
+func (m Movimento) diagonal() bool {
+	return m.Tipo == MOVIMENTO_DIAGONAL
+}
+
+// custoDiagonal é o custo de um passo diagonal para uma célula de custo reto.
+// A folga evita que erros de ponto flutuante (5 × 1,2) subam um inteiro.
+func (m Movimento) custoDiagonal(custo int) int {
+	if diagonal := int(math.Ceil(float64(custo)*m.CustoDiagonal - 1e-9)); diagonal > custo {
+		return diagonal
+	}
+	return custo
+}
+
+// passos é o menor número de passos entre a e b no modelo.
+func (m Movimento) passos(a, b Point) int {
+	dx, dy := abs(a.X-b.X), abs(a.Y-b.Y)
+	switch m.Tipo {
+	case MOVIMENTO_DIAGONAL:
+		return max(dx, dy)
+	case MOVIMENTO_HEXAGONAL:
+		return max(dx, dy, abs(a.X-b.X+a.Y-b.Y))
+	}
+	return distanciaManhattan(a, b)
+}
+
+// cortaQuina diz se o passo diagonal de p por d é barrado pela regra das
+// quinas.
+func (g *Game) cortaQuina(p, d Point) bool {
+	bloqueadas := 0
+	for _, lado := range [2]Point{{p.X + d.X, p.Y}, {p.X, p.Y + d.Y}} {
+		if !g.transitavel(lado) {
+			bloqueadas++
+		}
+	}
+	switch g.Movimento.Cantos {
+	case CANTOS_PERMITIR:
+		return false
+	case CANTOS_SEM_ESPREMER:
+		return bloqueadas == 2
+	}
+	return bloqueadas > 0
+}
+
+// custoPasso é o custo de ir de de até o vizinho para.
+func (g *Game) custoPasso(de, para Point) int {
+	custo := g.custoMovimento(para)
+	if g.Movimento.diagonal() && de.X != para.X && de.Y != para.Y {
+		return g.Movimento.custoDiagonal(custo)
+	}
+	return custo
+}
+
+// limiteCaminhada devolve um limite inferior do custo de caminhada entre dois
+// pontos, dado o menor custo de entrar numa célula. Como o passo diagonal
+// nunca custa menos que o reto, o menor passo diagonal é o da célula mais
+// barata; acima de dois passos retos ele nunca compensa.
+func (m Movimento) limiteCaminhada(minimo int) func(a, b Point) int {
+	if m.diagonal() {
+		minimoDiagonal := min(m.custoDiagonal(minimo), 2*minimo)
+		return func(a, b Point) int {
+			dx, dy := abs(a.X-b.X), abs(a.Y-b.Y)
+			return minimo*(max(dx, dy)-min(dx, dy)) + minimoDiagonal*min(dx, dy)
+		}
+	}
+	return func(a, b Point) int {
+		return minimo * m.passos(a, b)
+	}
+}
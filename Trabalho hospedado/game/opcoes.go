@@ -0,0 +1,143 @@
+package game
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ---------------- Opções de busca ----------------
+const (
+	MODO_LIVRE          = "livre"
+	MODO_ORDEM_ZODIACAL = "ordem_zodiacal"
+)
+
+type OpcoesBusca struct {
+	// Modo define se as casas podem ser conquistadas em qualquer ordem
+	// (livre) ou obrigatoriamente na ordem de Casas (ordem_zodiacal).
+	Modo string
+
+	// Algoritmo é o nome de um Solver registrado em SOLVERS (padrão astar)
+	Algoritmo string
+	// Peso da heurística no A* ponderado
+	Peso float64
+	// Heuristica é o nome de uma heurística de HEURISTICAS (padrão classica)
+	Heuristica string
+
+	// Prazo em minutos, na mesma unidade de CustoTotal (padrão 12 horas)
+	Prazo int
+	// Objetivo todas_casas (padrão) ou maximo_casas, que conquista o máximo
+	// de casas possível dentro do prazo (só com held_karp)
+	Objetivo string
+
+	// Orçamentos opcionais (zero = sem limite). Ao estourar, a busca devolve
+	// o melhor nó alcançado até ali, com Abortada preenchido.
+	MaximoNos   int
+	LimiteTempo time.Duration
+
+	// Suavizar transforma o caminho em trajeto de linhas retas (ver
+	// suavizarCaminho), sem aumentar o custo
+	Suavizar bool
+
+	// Rastrear, se presente, recebe cada nó expandido (ver rastro.go)
+	Rastrear func(PassoRastro)
+}
+
+func (o OpcoesBusca) Validar() error {
+	var erros ErrosValidacao
+	switch o.Modo {
+	case "", MODO_LIVRE, MODO_ORDEM_ZODIACAL:
+	default:
+		erros.adicionar("modo", "modo desconhecido %q (use %s ou %s)", o.Modo, MODO_LIVRE, MODO_ORDEM_ZODIACAL)
+	}
+
+	if _, err := ObterSolver(o.Algoritmo); err != nil {
+		erros.adicionar("algoritmo", "%v", err)
+	}
+	if _, err := ObterHeuristica(o.Heuristica); err != nil {
+		erros.adicionar("heuristica", "%v", err)
+	}
+	if o.Peso < 0 || (o.Peso > 0 && o.Peso < 1) {
+		erros.adicionar("peso", "deve ser ao menos 1, recebido %g", o.Peso)
+	}
+	switch o.Objetivo {
+	case "", OBJETIVO_TODAS_CASAS:
+	case OBJETIVO_MAXIMO_CASAS:
+		if o.Algoritmo != "" && o.Algoritmo != ALGORITMO_HELD_KARP {
+			erros.adicionar("objetivo", "%s só está disponível com o algoritmo %s", OBJETIVO_MAXIMO_CASAS, ALGORITMO_HELD_KARP)
+		}
+	default:
+		erros.adicionar("objetivo", "objetivo desconhecido %q (use %s ou %s)", o.Objetivo, OBJETIVO_TODAS_CASAS, OBJETIVO_MAXIMO_CASAS)
+	}
+	if o.Prazo < 0 {
+		erros.adicionar("prazo", "não pode ser negativo, recebido %d", o.Prazo)
+	}
+	if o.MaximoNos < 0 {
+		erros.adicionar("max_nos", "não pode ser negativo, recebido %d", o.MaximoNos)
+	}
+	if o.LimiteTempo < 0 {
+		erros.adicionar("limite_ms", "não pode ser negativo, recebido %d", o.LimiteTempo.Milliseconds())
+	}
+
+	if len(erros) > 0 {
+		return erros
+	}
+	return nil
+}
+
+// OpcoesDaRequisicao lê as opções de busca dos parâmetros da URL.
+func OpcoesDaRequisicao(r *http.Request) (OpcoesBusca, error) {
+	query := r.URL.Query()
+	opcoes := OpcoesBusca{
+		Modo:       query.Get("modo"),
+		Algoritmo:  query.Get("algoritmo"),
+		Heuristica: query.Get("heuristica"),
+		Objetivo:   query.Get("objetivo"),
+	}
+
+	var erros ErrosValidacao
+	if peso := query.Get("peso"); peso != "" {
+		valor, err := strconv.ParseFloat(peso, 64)
+		if err != nil {
+			erros.adicionar("peso", "número inválido %q", peso)
+		}
+		opcoes.Peso = valor
+	}
+	if prazo := query.Get("prazo"); prazo != "" {
+		valor, err := strconv.Atoi(prazo)
+		if err != nil {
+			erros.adicionar("prazo", "número inválido %q", prazo)
+		}
+		opcoes.Prazo = valor
+	}
+	if maximo := query.Get("max_nos"); maximo != "" {
+		valor, err := strconv.Atoi(maximo)
+		if err != nil {
+			erros.adicionar("max_nos", "número inválido %q", maximo)
+		}
+		opcoes.MaximoNos = valor
+	}
+	if limite := query.Get("limite_ms"); limite != "" {
+		valor, err := strconv.Atoi(limite)
+		if err != nil {
+			erros.adicionar("limite_ms", "número inválido %q", limite)
+		}
+		opcoes.LimiteTempo = time.Duration(valor) * time.Millisecond
+	}
+	if suavizar := query.Get("suavizar"); suavizar != "" {
+		valor, err := strconv.ParseBool(suavizar)
+		if err != nil {
+			erros.adicionar("suavizar", "booleano inválido %q", suavizar)
+		}
+		opcoes.Suavizar = valor
+	}
+	if len(erros) > 0 {
+		return opcoes, fmt.Errorf("opções de busca: %w", erros)
+	}
+
+	if err := opcoes.Validar(); err != nil {
+		return opcoes, fmt.Errorf("opções de busca: %w", err)
+	}
+	return opcoes, nil
+}
@@ -0,0 +1,267 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// ---------------- Modo jogador ----------------
+// O jogador anda uma célula por jogada e escolhe a equipe ao entrar em cada
+// casa. A partida interativa fica numa SalaPartidas (sala.go), que recebe as
+// jogadas uma a uma e entrega os estados por long-poll. Jogar, sem sessão,
+// refaz uma lista inteira de jogadas a partir da Entrada: serve para conferir
+// uma partida gravada e, como o cliente manda a lista que quiser, não impede
+// que ele desfaça jogadas.
+
+type Jogada struct {
+	Para Point `json:"para"`
+	// Índices dos cavaleiros que lutam, só ao entrar numa casa não conquistada
+	Cavaleiros []int `json:"cavaleiros,omitempty"`
+}
+
+type Partida struct {
+	// Jogo opcional; sem ele vale o cenário padrão
+	Jogo    *Game    `json:"jogo,omitempty"`
+	Modo    string   `json:"modo,omitempty"`
+	Jogadas []Jogada `json:"jogadas"`
+}
+
+type EstadoPartida struct {
+	// ID da partida na SalaPartidas; vazio nas partidas refeitas por Jogar
+	ID             string             `json:"id,omitempty"`
+	Modo           string             `json:"modo"`
+	Posicao        Point              `json:"posicao"`
+	Caminho        []Point            `json:"caminho"`
+	Tempo          int                `json:"tempo"`
+	CustoCaminhada int                `json:"custo_caminhada"`
+	CustoBatalhas  int                `json:"custo_batalhas"`
+	Batalhas       []EtapaCasa        `json:"batalhas"`
+	Energia        []EnergiaCavaleiro `json:"energia"`
+	// Células para onde o jogador pode ir na próxima jogada
+	Movimentos []Point          `json:"movimentos"`
+	Concluida  bool             `json:"concluida"`
+	Comparacao *ComparacaoOtimo `json:"comparacao,omitempty"`
+	// Número de jogadas feitas
+	Jogadas int `json:"jogadas"`
+}
+
+// ComparacaoOtimo confronta o tempo do jogador com o ótimo do mesmo modo,
+// calculado pelo Held-Karp (mesmo custo do A* com heurística admissível).
+type ComparacaoOtimo struct {
+	CustoJogador int     `json:"custo_jogador"`
+	CustoOtimo   int     `json:"custo_otimo"`
+	Diferenca    int     `json:"diferenca"`
+	Algoritmo    string  `json:"algoritmo"`
+	CaminhoOtimo []Point `json:"caminho_otimo"`
+}
+
+// LerPartida decodifica uma partida e prepara o jogo em que ela acontece.
+func LerPartida(r io.Reader) (*Game, Partida, error) {
+	var partida Partida
+	if err := json.NewDecoder(r).Decode(&partida); err != nil {
+		return nil, partida, erroDecodificacao(err)
+	}
+	g, err := jogoDaPartida(partida.Jogo)
+	return g, partida, err
+}
+
+// jogoDaPartida normaliza o jogo enviado; sem ele vale o cenário padrão.
+func jogoDaPartida(jogo *Game) (*Game, error) {
+	if jogo == nil {
+		return JogoPadrao()
+	}
+	if err := jogo.normalizar(); err != nil {
+		return nil, err
+	}
+	return jogo, nil
+}
+
+// Jogar refaz as jogadas a partir da Entrada e devolve o estado resultante. A
+// primeira jogada inválida interrompe a partida com um ErrosValidacao.
+func (g *Game) Jogar(modo string, jogadas []Jogada) (EstadoPartida, error) {
+	if modo == "" {
+		modo = MODO_LIVRE
+	}
+	if err := g.validarPartida(modo); err != nil {
+		return EstadoPartida{}, err
+	}
+
+	grade := g.prepararGrade()
+	estado := EstadoPartida{
+		Modo:     modo,
+		Posicao:  g.Entrada,
+		Caminho:  []Point{g.Entrada},
+		Batalhas: []EtapaCasa{},
+		Energia:  make([]EnergiaCavaleiro, len(g.Cavaleiros)),
+	}
+	for i, cavaleiro := range g.Cavaleiros {
+		estado.Energia[i] = EnergiaCavaleiro{Nome: cavaleiro.Nome, Energia: cavaleiro.Energia}
+	}
+
+	for i, jogada := range jogadas {
+		if err := g.aplicarJogada(grade, &estado, fmt.Sprintf("jogadas[%d]", i), jogada); err != nil {
+			return estado, err
+		}
+	}
+	g.encerrarJogada(grade, &estado)
+	return estado, nil
+}
+
+func (g *Game) validarPartida(modo string) error {
+	if modo != MODO_LIVRE && modo != MODO_ORDEM_ZODIACAL {
+		return ErrosValidacao{{Campo: "modo", Mensagem: fmt.Sprintf("modo desconhecido %q (use %s ou %s)", modo, MODO_LIVRE, MODO_ORDEM_ZODIACAL)}}
+	}
+	if len(g.Casas) > MAXIMO_CASAS {
+		return fmt.Errorf("no máximo %d casas, recebidas %d", MAXIMO_CASAS, len(g.Casas))
+	}
+	return nil
+}
+
+// copiar devolve um estado que pode receber jogadas sem alterar este.
+func (e EstadoPartida) copiar() EstadoPartida {
+	e.Caminho = append([]Point(nil), e.Caminho...)
+	e.Batalhas = append([]EtapaCasa(nil), e.Batalhas...)
+	e.Energia = append([]EnergiaCavaleiro(nil), e.Energia...)
+	return e
+}
+
+// conquistadas é a máscara das casas já vencidas no estado.
+func (e EstadoPartida) conquistadas() uint64 {
+	var mascara uint64
+	for _, etapa := range e.Batalhas {
+		mascara |= 1 << etapa.CasaID
+	}
+	return mascara
+}
+
+// liberada diz se a casa pode ser atravessada agora
+func (e EstadoPartida) liberada(casaID int) bool {
+	return casaID < 0 || e.conquistadas()&(1<<casaID) != 0 || e.Modo != MODO_ORDEM_ZODIACAL || casaID == len(e.Batalhas)
+}
+
+// aplicarJogada valida e aplica uma jogada. Em caso de erro o estado pode
+// ficar com o passo dado, como na partida refeita até a jogada inválida.
+func (g *Game) aplicarJogada(grade grade, estado *EstadoPartida, campo string, jogada Jogada) error {
+	var erros ErrosValidacao
+	conquistadas := estado.conquistadas()
+	energiaTotal := 0
+	for _, cavaleiro := range estado.Energia {
+		energiaTotal += max(cavaleiro.Energia, 0)
+	}
+
+	if estado.Posicao == g.GrandeMestre && conquistadas == mascaraCompleta(len(g.Casas)) {
+		erros.adicionar(campo, "a partida já terminou")
+		return erros
+	}
+
+	adjacente := false
+	for _, vizinho := range g.obterVizinhos(estado.Posicao) {
+		adjacente = adjacente || vizinho == jogada.Para
+	}
+	if !adjacente {
+		erros.adicionar(campo+".para", "%v não é vizinho de %v", jogada.Para, estado.Posicao)
+		return erros
+	}
+
+	celula := grade.celula(jogada.Para)
+	casaID := grade.casa[celula]
+	batalha := casaID >= 0 && conquistadas&(1<<casaID) == 0
+	if !estado.liberada(casaID) {
+		erros.adicionar(campo+".para", "na ordem zodiacal a próxima casa é %s", g.Casas[len(estado.Batalhas)].Nome)
+		return erros
+	}
+	if !batalha && len(jogada.Cavaleiros) > 0 {
+		erros.adicionar(campo+".cavaleiros", "só se escolhe equipe ao entrar numa casa não conquistada")
+		return erros
+	}
+
+	custo := grade.custoPasso(estado.Posicao, jogada.Para, celula)
+	estado.Tempo += custo
+	estado.CustoCaminhada += custo
+	estado.Posicao = jogada.Para
+	estado.Caminho = append(estado.Caminho, jogada.Para)
+	estado.Jogadas++
+	if !batalha {
+		return nil
+	}
+
+	if len(jogada.Cavaleiros) == 0 {
+		erros.adicionar(campo+".cavaleiros", "escolha ao menos um cavaleiro para enfrentar %s", g.Casas[casaID].Nome)
+		return erros
+	}
+	escolhidos := make(map[int]bool, len(jogada.Cavaleiros))
+	for _, indice := range jogada.Cavaleiros {
+		switch {
+		case indice < 0 || indice >= len(g.Cavaleiros):
+			erros.adicionar(campo+".cavaleiros", "cavaleiro %d inexistente", indice)
+		case escolhidos[indice]:
+			erros.adicionar(campo+".cavaleiros", "%s escolhido mais de uma vez", g.Cavaleiros[indice].Nome)
+		case estado.Energia[indice].Energia <= 0:
+			erros.adicionar(campo+".cavaleiros", "%s está sem energia", g.Cavaleiros[indice].Nome)
+		}
+		escolhidos[indice] = true
+	}
+	// Como em planejarBatalhas: cada casa restante precisa de um ponto de
+	// energia e um cavaleiro tem de chegar vivo ao Grande Mestre
+	restantes := len(g.Casas) - len(estado.Batalhas) - 1
+	if len(erros) == 0 && energiaTotal-len(jogada.Cavaleiros) < restantes+1 {
+		erros.adicionar(campo+".cavaleiros", "essa equipe deixaria energia insuficiente para as %d casas restantes", restantes)
+	}
+	if len(erros) > 0 {
+		return erros
+	}
+
+	ultimaSaida := 0
+	if len(estado.Batalhas) > 0 {
+		ultimaSaida = estado.Batalhas[len(estado.Batalhas)-1].Saida
+	}
+	etapa := EtapaCasa{
+		Batalha: Batalha{
+			CasaID:     casaID,
+			Casa:       g.Casas[casaID].Nome,
+			Cavaleiros: []string{},
+			equipe:     jogada.Cavaleiros,
+		},
+		Passo:          estado.Jogadas,
+		Chegada:        estado.Tempo,
+		CustoCaminhada: estado.Tempo - ultimaSaida,
+	}
+	for _, indice := range jogada.Cavaleiros {
+		etapa.Cavaleiros = append(etapa.Cavaleiros, g.Cavaleiros[indice].Nome)
+		estado.Energia[indice].Energia--
+	}
+	etapa.Tempo = g.tempoBatalha(casaID, jogada.Cavaleiros)
+	estado.Tempo += minutosBatalha(etapa.Tempo)
+	estado.CustoBatalhas += minutosBatalha(etapa.Tempo)
+	etapa.Saida = estado.Tempo
+
+	estado.Batalhas = append(estado.Batalhas, etapa)
+	return nil
+}
+
+// encerrarJogada completa o estado depois da última jogada: a comparação com
+// o ótimo, se a partida acabou, ou os movimentos possíveis.
+func (g *Game) encerrarJogada(grade grade, estado *EstadoPartida) {
+	estado.Movimentos, estado.Comparacao = nil, nil
+	estado.Concluida = estado.Posicao == g.GrandeMestre && estado.conquistadas() == mascaraCompleta(len(g.Casas))
+	if estado.Concluida {
+		otimo := g.Buscar(OpcoesBusca{Modo: estado.Modo, Algoritmo: ALGORITMO_HELD_KARP})
+		if otimo.Sucesso {
+			estado.Comparacao = &ComparacaoOtimo{
+				CustoJogador: estado.Tempo,
+				CustoOtimo:   otimo.CustoTotal,
+				Diferenca:    estado.Tempo - otimo.CustoTotal,
+				Algoritmo:    otimo.Algoritmo,
+				CaminhoOtimo: otimo.Caminho,
+			}
+		}
+	} else {
+		estado.Movimentos = []Point{}
+		for _, vizinho := range g.obterVizinhos(estado.Posicao) {
+			if estado.liberada(grade.casa[grade.celula(vizinho)]) {
+				estado.Movimentos = append(estado.Movimentos, vizinho)
+			}
+		}
+	}
+}
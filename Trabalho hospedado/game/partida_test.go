@@ -0,0 +1,120 @@
+package game
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// jogadasDoOtimo transforma o caminho ótimo em jogadas, com as equipes das
+// batalhas da busca.
+func jogadasDoOtimo(t *testing.T, g *Game, modo string) ([]Jogada, ResultadoBusca) {
+	otimo := g.Buscar(OpcoesBusca{Modo: modo, Algoritmo: ALGORITMO_HELD_KARP})
+	if !otimo.Sucesso {
+		t.Fatalf("busca falhou: %s", otimo.Motivo)
+	}
+	indices := make(map[string]int)
+	for i, cavaleiro := range g.Cavaleiros {
+		indices[cavaleiro.Nome] = i
+	}
+	jogadas := make([]Jogada, len(otimo.Caminho)-1)
+	for i, p := range otimo.Caminho[1:] {
+		jogadas[i].Para = p
+	}
+	for _, etapa := range otimo.Batalhas {
+		for _, nome := range etapa.Cavaleiros {
+			jogadas[etapa.Passo-1].Cavaleiros = append(jogadas[etapa.Passo-1].Cavaleiros, indices[nome])
+		}
+	}
+	return jogadas, otimo
+}
+
+func TestPartidaJogadaAJogadaIgualARefeita(t *testing.T) {
+	g := NovoJogo()
+	sala := NovaSalaPartidas()
+	for _, modo := range []string{MODO_LIVRE, MODO_ORDEM_ZODIACAL} {
+		jogadas, otimo := jogadasDoOtimo(t, g, modo)
+
+		refeita, err := g.Jogar(modo, jogadas)
+		if err != nil {
+			t.Fatalf("%s: %v", modo, err)
+		}
+		estado, err := sala.Abrir(g, modo, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", modo, err)
+		}
+		id := estado.ID
+		for i, jogada := range jogadas {
+			if estado, err = sala.Jogar(id, JogadaNumerada{Numero: i, Jogada: jogada}); err != nil {
+				t.Fatalf("%s, jogada %d: %v", modo, i, err)
+			}
+		}
+
+		if !estado.Concluida || estado.Tempo != refeita.Tempo || estado.Tempo != otimo.CustoTotal {
+			t.Errorf("%s: jogada a jogada %d (concluída %v), refeita %d, ótimo %d", modo, estado.Tempo, estado.Concluida, refeita.Tempo, otimo.CustoTotal)
+		}
+		if estado.Comparacao == nil || estado.Comparacao.Diferenca != 0 {
+			t.Errorf("%s: comparação %+v", modo, estado.Comparacao)
+		}
+	}
+}
+
+// O estado fica no servidor: uma jogada com número antigo (repetida ou feita
+// sobre um estado anterior) e uma jogada inválida não mudam a partida.
+func TestSalaNaoVoltaNemAplicaJogadaInvalida(t *testing.T) {
+	g := NovoJogo()
+	sala := NovaSalaPartidas()
+	jogadas, _ := jogadasDoOtimo(t, g, MODO_LIVRE)
+	estado, err := sala.Abrir(g, MODO_LIVRE, jogadas[:3])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sala.Jogar(estado.ID, JogadaNumerada{Numero: 2, Jogada: jogadas[2]}); err == nil {
+		t.Error("aceitou jogada com número antigo")
+	}
+	if _, err := sala.Jogar(estado.ID, JogadaNumerada{Numero: 3, Jogada: Jogada{Para: g.GrandeMestre}}); err == nil {
+		t.Error("aceitou jogada para célula não vizinha")
+	}
+	if _, err := sala.Jogar("outra", JogadaNumerada{Numero: 3, Jogada: jogadas[3]}); err == nil {
+		t.Error("aceitou partida inexistente")
+	}
+
+	atual, err := sala.Aguardar(context.Background(), estado.ID, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if atual.Jogadas != 3 || atual.Posicao != estado.Posicao || atual.Tempo != estado.Tempo {
+		t.Errorf("estado mudou: %d jogadas em %v, tempo %d", atual.Jogadas, atual.Posicao, atual.Tempo)
+	}
+	if _, err := sala.Jogar(estado.ID, JogadaNumerada{Numero: 3, Jogada: jogadas[3]}); err != nil {
+		t.Errorf("recusou a jogada legítima: %v", err)
+	}
+}
+
+func TestSalaAguardarAcordaNaJogada(t *testing.T) {
+	g := NovoJogo()
+	sala := NovaSalaPartidas()
+	jogadas, _ := jogadasDoOtimo(t, g, MODO_LIVRE)
+	estado, err := sala.Abrir(g, MODO_LIVRE, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recebido := make(chan EstadoPartida)
+	go func() {
+		novo, _ := sala.Aguardar(context.Background(), estado.ID, 0)
+		recebido <- novo
+	}()
+	if _, err := sala.Jogar(estado.ID, JogadaNumerada{Numero: 0, Jogada: jogadas[0]}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case novo := <-recebido:
+		if novo.Jogadas != 1 || novo.Posicao != jogadas[0].Para {
+			t.Errorf("long-poll devolveu %d jogadas em %v", novo.Jogadas, novo.Posicao)
+		}
+	case <-time.After(ESPERA_MAXIMA_PARTIDA / 2):
+		t.Fatal("long-poll não acordou com a jogada")
+	}
+}
@@ -0,0 +1,280 @@
+package game
+
+import (
+	"context"
+	"fmt"
+	"math/bits"
+	"sort"
+	"time"
+)
+
+// ---------------- As Doze Horas ----------------
+// Os cavaleiros têm doze horas para chegar ao Grande Mestre. Toda busca informa
+// se o CustoTotal cabe no prazo; com o objetivo maximo_casas, o Held-Karp
+// procura a rota que conquista o maior número de casas dentro do prazo (e,
+// entre essas, a mais rápida), quando a travessia completa não cabe.
+
+const (
+	PRAZO_PADRAO = 12 * 60
+
+	OBJETIVO_TODAS_CASAS  = "todas_casas"
+	OBJETIVO_MAXIMO_CASAS = "maximo_casas"
+)
+
+// candidatoCasas é um subconjunto de casas com um limite inferior da
+// caminhada que o percorre e do tempo total.
+type candidatoCasas struct {
+	mascara   int
+	caminhada int
+	minimo    int
+}
+
+// maximizarCasas devolve a travessia completa do Held-Karp quando ela cabe no
+// prazo. Senão, procura entre os subconjuntos de casas (na ordem zodiacal, só
+// os prefixos de Casas) o maior que cabe e, entre esses, o mais rápido. A
+// caminhada com todas as casas atravessáveis é um limite inferior que poda os
+// subconjuntos; os que sobram têm a caminhada exata calculada atravessando só
+// as suas casas, então o resultado é ótimo quando os planos de batalha são.
+func (g *Game) maximizarCasas(ctx context.Context, opcoes OpcoesBusca) ResultadoBusca {
+	inicio := time.Now()
+	limites := novoOrcamento(ctx, opcoes)
+	var estatisticas Estatisticas
+
+	falha := func(motivo string) ResultadoBusca {
+		return resultadoFalha(opcoes, motivo, estatisticas, inicio)
+	}
+	abortada := func(motivo string) ResultadoBusca {
+		resultado := falha(descreverInterrupcao(motivo, estatisticas.NosExpandidos))
+		resultado.Abortada = motivo
+		return resultado
+	}
+
+	n := len(g.Casas)
+	if n > MAXIMO_CASAS_HELD_KARP {
+		return falha(fmt.Sprintf("maximo_casas aceita no máximo %d casas, recebidas %d", MAXIMO_CASAS_HELD_KARP, n))
+	}
+	ordenado := opcoes.Modo == MODO_ORDEM_ZODIACAL
+
+	todas := opcoes
+	todas.Objetivo = OBJETIVO_TODAS_CASAS
+	completa := HeldKarp{}.Resolver(ctx, g, todas)
+	if completa.Abortada != "" || (completa.Sucesso && completa.CustoTotal <= opcoes.Prazo) {
+		return completa
+	}
+	// O orçamento de nós vale para as duas etapas juntas
+	estatisticas.NosExpandidos = completa.Estatisticas.NosExpandidos
+	estatisticas.NosGerados = completa.Estatisticas.NosGerados
+
+	gr := g.prepararGrade()
+	_, matriz, motivo := g.distanciasEntreMarcos(gr, todasCasas, limites, &estatisticas)
+	if motivo != "" {
+		return abortada(motivo)
+	}
+	var tabela tabelaHeldKarp
+	if !ordenado {
+		if tabela, motivo = g.montarHeldKarp(matriz, limites, &estatisticas); motivo != "" {
+			return abortada(motivo)
+		}
+	}
+
+	// Nenhuma equipe é mais forte que todos os cavaleiros juntos
+	poderTotal := 0.0
+	for _, cavaleiro := range g.Cavaleiros {
+		poderTotal += cavaleiro.PoderCosmico
+	}
+	minimoCasa := make([]int, n)
+	for i, casa := range g.Casas {
+		minimoCasa[i] = minutosBatalha(float64(casa.Dificuldade) / poderTotal)
+	}
+
+	// Na ordem zodiacal só um prefixo de Casas pode ser conquistado
+	mascaras := make([]int, 0, n+1)
+	for mascara := 0; mascara < 1<<n; mascara++ {
+		if !ordenado || mascara&(mascara+1) == 0 {
+			mascaras = append(mascaras, mascara)
+		}
+	}
+
+	porTamanho := make([][]candidatoCasas, n+1)
+	for _, mascara := range mascaras {
+		_, caminhada := tabela.rota(matriz, mascara)
+		if ordenado {
+			_, caminhada = rotaOrdenada(matriz, mascara)
+		}
+		if caminhada == SEM_CAMINHO {
+			continue
+		}
+		candidato := candidatoCasas{mascara: mascara, caminhada: caminhada, minimo: caminhada}
+		for i := 0; i < n; i++ {
+			if mascara&(1<<i) != 0 {
+				candidato.minimo += minimoCasa[i]
+			}
+		}
+		if candidato.minimo <= opcoes.Prazo {
+			tamanho := bits.OnesCount(uint(mascara))
+			porTamanho[tamanho] = append(porTamanho[tamanho], candidato)
+		}
+	}
+
+	// Do maior número de casas para o menor, avalia batalhas e caminhada
+	// exata só enquanto o limite inferior ainda pode bater o melhor total
+	for tamanho := n; tamanho >= 0; tamanho-- {
+		candidatos := porTamanho[tamanho]
+		sort.Slice(candidatos, func(i, j int) bool { return candidatos[i].minimo < candidatos[j].minimo })
+
+		melhorTotal := opcoes.Prazo + 1
+		var melhorPlano Atribuicao
+		var melhorRota rotaCasas
+		for _, candidato := range candidatos {
+			if candidato.minimo >= melhorTotal {
+				break
+			}
+			if motivo := limites.esgotado(estatisticas.NosExpandidos); motivo != "" {
+				return abortada(motivo)
+			}
+
+			plano, err := g.planoDasCasas(candidato.mascara)
+			if err != nil {
+				continue
+			}
+			batalhas := 0
+			for _, batalha := range plano.Batalhas {
+				batalhas += minutosBatalha(batalha.Tempo)
+			}
+			if candidato.caminhada+batalhas >= melhorTotal {
+				continue
+			}
+
+			rota, motivo := g.rotaDasCasas(gr, candidato.mascara, ordenado, limites, &estatisticas)
+			if motivo != "" {
+				return abortada(motivo)
+			}
+			if rota.caminhada != SEM_CAMINHO && rota.caminhada+batalhas < melhorTotal {
+				melhorTotal, melhorPlano, melhorRota = rota.caminhada+batalhas, plano, rota
+			}
+		}
+		if melhorTotal > opcoes.Prazo {
+			continue
+		}
+
+		caminho := g.costurarRota(gr, melhorRota.arvores, melhorRota.ordem, &estatisticas)
+		resultado := g.resultadoDoCaminho(opcoes, caminho, melhorPlano, estatisticas, inicio)
+		resultado.OtimoGarantido = melhorPlano.Otima
+		return resultado
+	}
+
+	return falha(fmt.Sprintf("nenhuma rota chega ao Grande Mestre em %d minutos", opcoes.Prazo))
+}
+
+// rotaOrdenada percorre as casas de mascara na ordem zodiacal.
+func rotaOrdenada(matriz [][]int, mascara int) ([]int, int) {
+	var ordem []int
+	origem, custo := 0, 0
+	for i := 0; i < len(matriz)-2; i++ {
+		if mascara&(1<<i) == 0 {
+			continue
+		}
+		if matriz[origem][i+1] == SEM_CAMINHO {
+			return nil, SEM_CAMINHO
+		}
+		custo += matriz[origem][i+1]
+		ordem = append(ordem, i)
+		origem = i + 1
+	}
+	if matriz[origem][len(matriz)-1] == SEM_CAMINHO {
+		return nil, SEM_CAMINHO
+	}
+	return ordem, custo + matriz[origem][len(matriz)-1]
+}
+
+// rotaCasas é a rota exata por um subconjunto de casas: a ordem de visita,
+// as árvores de caminhos mínimos por marco de origem (indexadas como em
+// marcos) e a caminhada, ou SEM_CAMINHO.
+type rotaCasas struct {
+	ordem     []int
+	arvores   []arvoreCaminhos
+	caminhada int
+}
+
+// rotaDasCasas calcula a menor caminhada da Entrada ao Grande Mestre que
+// conquista exatamente as casas de mascara: as demais não podem ser
+// atravessadas. No modo livre as casas da máscara servem de passagem a
+// qualquer momento e a ordem sai do Held-Karp; na ordem zodiacal cada trecho
+// só atravessa as casas já conquistadas.
+func (g *Game) rotaDasCasas(gr grade, mascara int, ordenado bool, limites orcamento, estatisticas *Estatisticas) (rotaCasas, string) {
+	marcos := g.marcos()
+	mestre := len(marcos) - 1
+	// Marcos da rota: a Entrada e as casas da máscara, de onde saem trechos
+	origens := []int{0}
+	for i := range g.Casas {
+		if mascara&(1<<i) != 0 {
+			origens = append(origens, i+1)
+		}
+	}
+
+	rota := rotaCasas{arvores: make([]arvoreCaminhos, len(marcos)), caminhada: SEM_CAMINHO}
+	for _, origem := range origens {
+		atravessa := func(casaID int) bool { return mascara&(1<<casaID) != 0 }
+		if ordenado {
+			liberadas := origem
+			atravessa = func(casaID int) bool { return casaID < liberadas }
+		}
+		var motivo string
+		if rota.arvores[origem], motivo = g.caminhosMinimos(gr, marcos[origem], atravessa, limites, estatisticas); motivo != "" {
+			return rota, motivo
+		}
+	}
+
+	// Matriz só entre os marcos da rota, com o Grande Mestre por último
+	destinos := append(append([]int(nil), origens...), mestre)
+	matriz := make([][]int, len(destinos))
+	for i, origem := range destinos {
+		matriz[i] = make([]int, len(destinos))
+		for j, destino := range destinos {
+			matriz[i][j] = SEM_CAMINHO
+			if origem != mestre {
+				matriz[i][j] = rota.arvores[origem].custo[gr.celula(marcos[destino])]
+			}
+		}
+	}
+
+	var ordem []int
+	if ordenado {
+		ordem, rota.caminhada = rotaOrdenada(matriz, 1<<(len(destinos)-2)-1)
+	} else {
+		tabela, motivo := g.montarHeldKarp(matriz, limites, estatisticas)
+		if motivo != "" {
+			return rota, motivo
+		}
+		ordem, rota.caminhada = tabela.rota(matriz, 1<<(len(destinos)-2)-1)
+	}
+	for _, local := range ordem {
+		rota.ordem = append(rota.ordem, destinos[local+1]-1)
+	}
+	return rota, ""
+}
+
+// planoDasCasas planeja as batalhas só das casas de mascara, com a energia
+// inteira disponível para elas. As batalhas seguem indexadas como em g.Casas.
+func (g *Game) planoDasCasas(mascara int) (Atribuicao, error) {
+	var indices []int
+	parcial := &Game{Cavaleiros: g.Cavaleiros}
+	for i, casa := range g.Casas {
+		if mascara&(1<<i) != 0 {
+			indices = append(indices, i)
+			parcial.Casas = append(parcial.Casas, casa)
+		}
+	}
+
+	plano, err := parcial.planejarBatalhas()
+	if err != nil {
+		return Atribuicao{}, err
+	}
+	batalhas := make([]Batalha, len(g.Casas))
+	for i, batalha := range plano.Batalhas {
+		batalha.CasaID = indices[i]
+		batalhas[indices[i]] = batalha
+	}
+	plano.Batalhas = batalhas
+	return plano, nil
+}
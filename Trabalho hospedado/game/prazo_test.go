@@ -0,0 +1,73 @@
+package game
+
+import (
+	"fmt"
+	"testing"
+)
+
+func jogosPrazo(t *testing.T) map[string]*Game {
+	jogos := map[string]*Game{"padrao": NovoJogo()}
+	for _, semente := range []int64{1, 7, 23} {
+		g, err := GerarSantuario(ParametrosGeracao{Semente: semente, Tamanho: 24, Casas: 5, Densidade: 0.5, Sinuosidade: 0.35})
+		if err != nil {
+			t.Fatal(err)
+		}
+		jogos[fmt.Sprintf("semente_%d", semente)] = g
+	}
+	return jogos
+}
+
+// Quando a travessia completa cabe no prazo, maximo_casas devolve o mesmo
+// custo do held_karp, e a rota é ótima.
+func TestMaximoCasasIgualAoHeldKarpQuandoCabe(t *testing.T) {
+	for nome, g := range jogosPrazo(t) {
+		for _, modo := range []string{MODO_LIVRE, MODO_ORDEM_ZODIACAL} {
+			t.Run(nome+"/"+modo, func(t *testing.T) {
+				completa := g.Buscar(OpcoesBusca{Modo: modo, Algoritmo: ALGORITMO_HELD_KARP})
+				if !completa.Sucesso {
+					t.Fatalf("held_karp falhou: %s", completa.Motivo)
+				}
+				for _, prazo := range []int{completa.CustoTotal, completa.CustoTotal + 60} {
+					r := g.Buscar(OpcoesBusca{Modo: modo, Algoritmo: ALGORITMO_HELD_KARP, Objetivo: OBJETIVO_MAXIMO_CASAS, Prazo: prazo})
+					if !r.Sucesso || r.CustoTotal != completa.CustoTotal || r.OtimoGarantido != completa.OtimoGarantido {
+						t.Errorf("prazo %d: maximo_casas custou %d (ótimo %v), held_karp %d (ótimo %v)", prazo, r.CustoTotal, r.OtimoGarantido, completa.CustoTotal, completa.OtimoGarantido)
+					}
+				}
+			})
+		}
+	}
+}
+
+// Na ordem zodiacal as casas conquistadas dentro do prazo são sempre as
+// primeiras de Casas.
+func TestMaximoCasasOrdenadoConquistaPrefixo(t *testing.T) {
+	for nome, g := range jogosPrazo(t) {
+		t.Run(nome, func(t *testing.T) {
+			completa := g.Buscar(OpcoesBusca{Modo: MODO_ORDEM_ZODIACAL, Algoritmo: ALGORITMO_HELD_KARP})
+			anterior := -1
+			for prazo := completa.CustoTotal - 1; prazo > 0; prazo -= completa.CustoTotal / 8 {
+				r := g.Buscar(OpcoesBusca{Modo: MODO_ORDEM_ZODIACAL, Algoritmo: ALGORITMO_HELD_KARP, Objetivo: OBJETIVO_MAXIMO_CASAS, Prazo: prazo})
+				if !r.Sucesso {
+					continue
+				}
+				if r.CustoTotal > prazo {
+					t.Errorf("prazo %d: custo %d", prazo, r.CustoTotal)
+				}
+				conquistadas := 0
+				for i, visitada := range r.Estatisticas.CasasVisitadas {
+					if !visitada {
+						continue
+					}
+					if i != conquistadas {
+						t.Fatalf("prazo %d: casas %v não são um prefixo", prazo, r.Estatisticas.CasasVisitadas)
+					}
+					conquistadas++
+				}
+				if anterior >= 0 && conquistadas > anterior {
+					t.Errorf("prazo %d conquista %d casas, um prazo maior só %d", prazo, conquistadas, anterior)
+				}
+				anterior = conquistadas
+			}
+		})
+	}
+}
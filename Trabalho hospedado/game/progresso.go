@@ -0,0 +1,115 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/bits"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ---------------- Progresso em Server-Sent Events ----------------
+// A busca roda numa goroutine e alimenta um resumo pelo rastro; a goroutine
+// da requisição envia esse resumo a cada INTERVALO_PROGRESSO como evento
+// "progresso" e termina com um evento "resultado" contendo o ResultadoBusca.
+
+const INTERVALO_PROGRESSO = 250 * time.Millisecond
+
+type ProgressoBusca struct {
+	NosExpandidos int `json:"nos_expandidos"`
+	// MelhorF é o F do último nó expandido: no A* é o menor F da fronteira
+	MelhorF int `json:"melhor_f"`
+	// Casas conquistadas pelo nó mais avançado expandido até agora
+	Casas       []int  `json:"casas"`
+	Decorrido   string `json:"decorrido"`
+	DecorridoMs int64  `json:"decorrido_ms"`
+}
+
+// acompanhamento guarda o resumo compartilhado entre a busca e o envio.
+type acompanhamento struct {
+	mu         sync.Mutex
+	inicio     time.Time
+	expandidos int
+	melhorF    int
+	melhor     PassoRastro
+	temMelhor  bool
+}
+
+func (a *acompanhamento) registrar(passo PassoRastro) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.expandidos++
+	a.melhorF = passo.F
+	casas, casasMelhor := bits.OnesCount64(passo.Casas), bits.OnesCount64(a.melhor.Casas)
+	if !a.temMelhor || casas > casasMelhor || (casas == casasMelhor && passo.H < a.melhor.H) {
+		a.melhor, a.temMelhor = passo, true
+	}
+}
+
+func (a *acompanhamento) resumo() ProgressoBusca {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	decorrido := time.Since(a.inicio)
+	p := ProgressoBusca{
+		NosExpandidos: a.expandidos,
+		MelhorF:       a.melhorF,
+		Casas:         indicesDaMascara(a.melhor.Casas),
+		Decorrido:     decorrido.String(),
+		DecorridoMs:   decorrido.Milliseconds(),
+	}
+	return p
+}
+
+func enviarEvento(w http.ResponseWriter, evento string, dados interface{}) error {
+	conteudo, err := json.Marshal(dados)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", evento, conteudo); err != nil {
+		return err
+	}
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// TransmitirProgresso executa a busca enviando eventos SSE. Se o cliente
+// desconectar, o contexto da requisição cancela a busca.
+func TransmitirProgresso(w http.ResponseWriter, r *http.Request, g *Game, opcoes OpcoesBusca) error {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	estado := &acompanhamento{inicio: time.Now()}
+	rastrear := opcoes.Rastrear
+	opcoes.Rastrear = func(passo PassoRastro) {
+		estado.registrar(passo)
+		if rastrear != nil {
+			rastrear(passo)
+		}
+	}
+
+	concluida := make(chan ResultadoBusca, 1)
+	go func() {
+		concluida <- g.BuscarComContexto(r.Context(), opcoes)
+	}()
+
+	relogio := time.NewTicker(INTERVALO_PROGRESSO)
+	defer relogio.Stop()
+	for {
+		select {
+		case resultado := <-concluida:
+			if err := enviarEvento(w, "progresso", estado.resumo()); err != nil {
+				return err
+			}
+			return enviarEvento(w, "resultado", resultado)
+		case <-relogio.C:
+			if err := enviarEvento(w, "progresso", estado.resumo()); err != nil {
+				// A busca percebe o cancelamento pelo contexto e termina
+				return err
+			}
+		}
+	}
+}
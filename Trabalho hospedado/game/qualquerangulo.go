@@ -0,0 +1,153 @@
+package game
+
+import "math"
+
+// ---------------- Caminhos em qualquer ângulo ----------------
+// Um trajeto é uma polilinha de vértices; cada trecho entre dois vértices é
+// refeito célula a célula por tracarLinha, com passos válidos no modelo de
+// movimento, e cobrado exatamente pelas células atravessadas. O Theta* liga
+// nós ao avô quando há linha de visada e o suavizador estica um caminho
+// pronto, sem nunca aumentar o custo.
+
+// tracarLinha devolve as células da reta de a até b, sem a e com b: passos
+// ortogonais, a reta de Bresenham no movimento diagonal ou a linha hexagonal.
+func (g *Game) tracarLinha(a, b Point) []Point {
+	dx, dy := abs(b.X-a.X), abs(b.Y-a.Y)
+	sx, sy := sinal(b.X-a.X), sinal(b.Y-a.Y)
+	celulas := make([]Point, 0, dx+dy)
+
+	switch g.Movimento.Tipo {
+	case MOVIMENTO_DIAGONAL:
+		erro := dx - dy
+		for p := a; p != b; {
+			dobro := 2 * erro
+			if dobro > -dy {
+				erro -= dy
+				p.X += sx
+			}
+			if dobro < dx {
+				erro += dx
+				p.Y += sy
+			}
+			celulas = append(celulas, p)
+		}
+
+	case MOVIMENTO_HEXAGONAL:
+		// Interpola em coordenadas cúbicas (r, q, s = -r-q) e arredonda; o
+		// desvio desempata retas que passam exatamente entre dois hexágonos
+		passos := g.Movimento.passos(a, b)
+		for i := 1; i <= passos; i++ {
+			t := float64(i) / float64(passos)
+			r := float64(a.X) + float64(b.X-a.X)*t + 1e-6
+			q := float64(a.Y) + float64(b.Y-a.Y)*t + 2e-6
+			celulas = append(celulas, arredondarHexagono(r, q))
+		}
+
+	default:
+		// Anda no eixo cuja próxima fronteira de célula a reta cruza antes
+		p := a
+		for ix, iy := 0, 0; ix < dx || iy < dy; {
+			if (1+2*ix)*dy < (1+2*iy)*dx {
+				p.X += sx
+				ix++
+			} else {
+				p.Y += sy
+				iy++
+			}
+			celulas = append(celulas, p)
+		}
+	}
+	return celulas
+}
+
+func arredondarHexagono(r, q float64) Point {
+	s := -r - q
+	rr, rq, rs := math.Round(r), math.Round(q), math.Round(s)
+	dr, dq, ds := math.Abs(rr-r), math.Abs(rq-q), math.Abs(rs-s)
+	switch {
+	case dr > dq && dr > ds:
+		rr = -rq - rs
+	case dq > ds:
+		rq = -rr - rs
+	}
+	return Point{int(rr), int(rq)}
+}
+
+// segmento mede a reta de de até para com as casas da máscara conquistadas.
+// Ela só é livre se todas as células forem transitáveis, os passos diagonais
+// respeitarem as quinas e nenhuma casa pendente ficar no meio do caminho (só
+// no fim, onde há batalha). Devolve o número de passos e o custo.
+func (g *Game) segmento(gr grade, de, para Point, mascara uint64) (int, int, bool) {
+	celulas := g.tracarLinha(de, para)
+	custo := 0
+	anterior := de
+	for i, p := range celulas {
+		celula := gr.celula(p)
+		if gr.custo[celula] < 0 {
+			return 0, 0, false
+		}
+		d := Point{p.X - anterior.X, p.Y - anterior.Y}
+		if g.Movimento.diagonal() && d.X != 0 && d.Y != 0 && g.cortaQuina(anterior, d) {
+			return 0, 0, false
+		}
+		if casaID := gr.casa[celula]; casaID >= 0 && mascara&(1<<casaID) == 0 && i < len(celulas)-1 {
+			return 0, 0, false
+		}
+		custo += gr.custoPasso(anterior, p, celula)
+		anterior = p
+	}
+	return len(celulas), custo, true
+}
+
+// percorrer refaz um trajeto célula a célula. Um caminho de células vizinhas
+// volta igual.
+func (g *Game) percorrer(trajeto []Point) []Point {
+	if len(trajeto) == 0 {
+		return trajeto
+	}
+	caminho := []Point{trajeto[0]}
+	for i := 1; i < len(trajeto); i++ {
+		caminho = append(caminho, g.tracarLinha(trajeto[i-1], trajeto[i])...)
+	}
+	return caminho
+}
+
+// suavizarCaminho puxa o caminho como um barbante: de cada vértice vai direto
+// à célula mais adiante que ainda tem linha de visada e cuja reta não custa
+// mais que o trecho original. As batalhas são sempre vértices, para que as
+// casas sejam conquistadas nas mesmas células e na mesma ordem.
+func (g *Game) suavizarCaminho(caminho []Point) []Point {
+	if len(caminho) < 3 {
+		return append([]Point(nil), caminho...)
+	}
+	gr := g.prepararGrade()
+
+	// acumulado[i]: custo de caminhada de caminho[0] até caminho[i]
+	acumulado := make([]int, len(caminho))
+	for i := 1; i < len(caminho); i++ {
+		acumulado[i] = acumulado[i-1] + gr.custoPasso(caminho[i-1], caminho[i], gr.celula(caminho[i]))
+	}
+	batalha := func(i int, mascara uint64) bool {
+		casaID := gr.casa[gr.celula(caminho[i])]
+		return casaID >= 0 && mascara&(1<<casaID) == 0
+	}
+
+	trajeto := []Point{caminho[0]}
+	var mascara uint64
+	for ancora := 0; ancora < len(caminho)-1; {
+		fim := ancora + 1
+		for j := ancora + 2; j < len(caminho) && !batalha(j-1, mascara); j++ {
+			_, custo, livre := g.segmento(gr, caminho[ancora], caminho[j], mascara)
+			if !livre || custo > acumulado[j]-acumulado[ancora] {
+				break
+			}
+			fim = j
+		}
+		if batalha(fim, mascara) {
+			mascara |= 1 << gr.casa[gr.celula(caminho[fim])]
+		}
+		trajeto = append(trajeto, caminho[fim])
+		ancora = fim
+	}
+	return trajeto
+}
@@ -0,0 +1,125 @@
+package game
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ---------------- Rastro da busca ----------------
+// Com OpcoesBusca.Rastrear preenchido, o motor chama a função a cada nó
+// expandido. TransmitirRastro envia esses passos em blocos NDJSON (gzip
+// quando o cliente aceita), para a página animar a fronteira enquanto a busca
+// ainda roda; a última linha traz o ResultadoBusca.
+
+const (
+	TAMANHO_BLOCO_RASTRO = 500
+
+	// Sem max_nos explícito, o rastro para após este número de expansões. O
+	// A* no mapa padrão expande cerca de 30 mil nós com a heurística clássica
+	// e 340 mil com mst_batalhas no modo livre; Dijkstra e as heurísticas mais
+	// fracas passam disso e pedem max_nos.
+	LIMITE_RASTRO_PADRAO = 500000
+)
+
+type PassoRastro struct {
+	X         int    `json:"x"`
+	Y         int    `json:"y"`
+	G         int    `json:"g"`
+	H         int    `json:"h"`
+	F         int    `json:"f"`
+	Casas     uint64 `json:"-"`
+	Fronteira int    `json:"fronteira"`
+}
+
+// passoEnviado leva as casas como índices: a máscara de 64 bits não cabe
+// num número do JavaScript.
+type passoEnviado struct {
+	PassoRastro
+	Casas []int `json:"casas"`
+}
+
+type blocoRastro struct {
+	Passos    []passoEnviado  `json:"passos,omitempty"`
+	Resultado *ResultadoBusca `json:"resultado,omitempty"`
+}
+
+// escritorBlocos grava uma linha JSON por bloco e a empurra ao cliente.
+type escritorBlocos struct {
+	saida     io.Writer
+	gzip      *gzip.Writer
+	flusher   http.Flusher
+	codificar *json.Encoder
+}
+
+func novoEscritorBlocos(w http.ResponseWriter, r *http.Request) *escritorBlocos {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	e := &escritorBlocos{saida: w}
+	if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		w.Header().Set("Content-Encoding", "gzip")
+		e.gzip = gzip.NewWriter(w)
+		e.saida = e.gzip
+	}
+	e.flusher, _ = w.(http.Flusher)
+	e.codificar = json.NewEncoder(e.saida)
+	return e
+}
+
+func (e *escritorBlocos) enviar(bloco blocoRastro) error {
+	if err := e.codificar.Encode(bloco); err != nil {
+		return err
+	}
+	if e.gzip != nil {
+		if err := e.gzip.Flush(); err != nil {
+			return err
+		}
+	}
+	if e.flusher != nil {
+		e.flusher.Flush()
+	}
+	return nil
+}
+
+func (e *escritorBlocos) fechar() error {
+	if e.gzip != nil {
+		return e.gzip.Close()
+	}
+	return nil
+}
+
+// TransmitirRastro executa a busca com rastro ligado, enviando os passos em
+// blocos de TAMANHO_BLOCO_RASTRO. Se o cliente desconectar, a busca é
+// cancelada pelo contexto da requisição.
+func TransmitirRastro(w http.ResponseWriter, r *http.Request, g *Game, opcoes OpcoesBusca) error {
+	if opcoes.MaximoNos == 0 {
+		opcoes.MaximoNos = LIMITE_RASTRO_PADRAO
+	}
+
+	escritor := novoEscritorBlocos(w, r)
+	bloco := make([]passoEnviado, 0, TAMANHO_BLOCO_RASTRO)
+	var errEnvio error
+	opcoes.Rastrear = func(passo PassoRastro) {
+		bloco = append(bloco, passoEnviado{PassoRastro: passo, Casas: indicesDaMascara(passo.Casas)})
+		if len(bloco) < TAMANHO_BLOCO_RASTRO || errEnvio != nil {
+			return
+		}
+		errEnvio = escritor.enviar(blocoRastro{Passos: bloco})
+		bloco = bloco[:0]
+	}
+
+	resultado := g.BuscarComContexto(r.Context(), opcoes)
+	if errEnvio != nil {
+		return errEnvio
+	}
+	if len(bloco) > 0 {
+		if err := escritor.enviar(blocoRastro{Passos: bloco}); err != nil {
+			return err
+		}
+	}
+	if err := escritor.enviar(blocoRastro{Resultado: &resultado}); err != nil {
+		return err
+	}
+	return escritor.fechar()
+}
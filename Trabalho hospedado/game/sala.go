@@ -0,0 +1,162 @@
+package game
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// ---------------- Sala de partidas (long-poll) ----------------
+// A sala guarda na memória do processo o estado de cada partida aberta. O
+// cliente manda cada jogada com o número que espera que ela tenha (o total de
+// jogadas já feitas) e recebe o estado novo por long-poll: Aguardar segura a
+// requisição até a partida mudar ou ESPERA_MAXIMA_PARTIDA passar. Como o
+// estado nunca sai do servidor, não há como voltar a um estado anterior nem
+// forjar energia ou tempo.
+//
+// As partidas só existem na instância que as abriu: em serverless, a função
+// de /api/partida precisa ficar numa instância só para que as jogadas e a
+// espera cheguem à mesma sala.
+
+const (
+	// Abaixo do limite de duração das funções serverless
+	ESPERA_MAXIMA_PARTIDA = 8 * time.Second
+	// Partidas sem jogada nem consulta por este tempo são descartadas
+	EXPIRACAO_PARTIDA = 30 * time.Minute
+	MAXIMO_PARTIDAS   = 1000
+)
+
+type SalaPartidas struct {
+	mu       sync.Mutex
+	partidas map[string]*partidaAberta
+}
+
+type partidaAberta struct {
+	g      *Game
+	grade  grade
+	estado EstadoPartida
+	// Fechado e substituído a cada jogada, acorda quem está em Aguardar
+	mudou  chan struct{}
+	acesso time.Time
+}
+
+// JogadaNumerada é o corpo de uma jogada enviada a uma partida aberta.
+// Numero é o total de jogadas que o cliente já viu: uma jogada repetida ou
+// baseada num estado antigo é recusada.
+type JogadaNumerada struct {
+	Numero int `json:"numero"`
+	Jogada
+}
+
+func NovaSalaPartidas() *SalaPartidas {
+	return &SalaPartidas{partidas: make(map[string]*partidaAberta)}
+}
+
+func LerJogadaNumerada(r io.Reader) (JogadaNumerada, error) {
+	var jogada JogadaNumerada
+	if err := json.NewDecoder(r).Decode(&jogada); err != nil {
+		return jogada, erroDecodificacao(err)
+	}
+	return jogada, nil
+}
+
+// Abrir começa uma partida refazendo as jogadas iniciais (em geral nenhuma) e
+// devolve o estado com o ID que as próximas requisições usam.
+func (s *SalaPartidas) Abrir(g *Game, modo string, jogadas []Jogada) (EstadoPartida, error) {
+	estado, err := g.Jogar(modo, jogadas)
+	if err != nil {
+		return estado, err
+	}
+	identificador := make([]byte, 16)
+	if _, err := rand.Read(identificador); err != nil {
+		return EstadoPartida{}, err
+	}
+	estado.ID = hex.EncodeToString(identificador)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	agora := time.Now()
+	for id, partida := range s.partidas {
+		if agora.Sub(partida.acesso) > EXPIRACAO_PARTIDA {
+			delete(s.partidas, id)
+		}
+	}
+	if len(s.partidas) >= MAXIMO_PARTIDAS {
+		return EstadoPartida{}, fmt.Errorf("muitas partidas abertas (%d); tente mais tarde", MAXIMO_PARTIDAS)
+	}
+	s.partidas[estado.ID] = &partidaAberta{
+		g:      g,
+		grade:  g.prepararGrade(),
+		estado: estado,
+		mudou:  make(chan struct{}),
+		acesso: agora,
+	}
+	return estado, nil
+}
+
+func (s *SalaPartidas) partida(id string) (*partidaAberta, error) {
+	partida, existe := s.partidas[id]
+	if !existe {
+		return nil, ErrosValidacao{{Campo: "id", Mensagem: fmt.Sprintf("partida %q inexistente ou expirada; recomece", id)}}
+	}
+	partida.acesso = time.Now()
+	return partida, nil
+}
+
+// Jogar aplica uma jogada à partida. Uma jogada inválida não altera o estado.
+func (s *SalaPartidas) Jogar(id string, jogada JogadaNumerada) (EstadoPartida, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	partida, err := s.partida(id)
+	if err != nil {
+		return EstadoPartida{}, err
+	}
+	if jogada.Numero != partida.estado.Jogadas {
+		return EstadoPartida{}, ErrosValidacao{{Campo: "numero", Mensagem: fmt.Sprintf("esperada a jogada %d, recebida %d", partida.estado.Jogadas, jogada.Numero)}}
+	}
+
+	// O estado publicado não muda: quem o recebeu pode estar codificando-o
+	estado := partida.estado.copiar()
+	if err := partida.g.aplicarJogada(partida.grade, &estado, "jogada", jogada.Jogada); err != nil {
+		return EstadoPartida{}, err
+	}
+	partida.g.encerrarJogada(partida.grade, &estado)
+	partida.estado = estado
+	close(partida.mudou)
+	partida.mudou = make(chan struct{})
+	return estado, nil
+}
+
+// Aguardar devolve o estado assim que a partida tiver mais de desde jogadas,
+// ou o estado atual depois de ESPERA_MAXIMA_PARTIDA, para o cliente perguntar
+// de novo.
+func (s *SalaPartidas) Aguardar(ctx context.Context, id string, desde int) (EstadoPartida, error) {
+	espera := time.NewTimer(ESPERA_MAXIMA_PARTIDA)
+	defer espera.Stop()
+	for {
+		s.mu.Lock()
+		partida, err := s.partida(id)
+		if err != nil {
+			s.mu.Unlock()
+			return EstadoPartida{}, err
+		}
+		estado, mudou := partida.estado, partida.mudou
+		s.mu.Unlock()
+
+		if estado.Jogadas > desde {
+			return estado, nil
+		}
+		select {
+		case <-mudou:
+		case <-espera.C:
+			return estado, nil
+		case <-ctx.Done():
+			return estado, ctx.Err()
+		}
+	}
+}
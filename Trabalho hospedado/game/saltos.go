@@ -0,0 +1,153 @@
+package game
+
+// ---------------- Jump Point Search ----------------
+// Em regiões de terreno uniforme o A* expande muitos caminhos simétricos de
+// mesmo custo. O JPS (na versão para 4 vizinhos) troca cada vizinho por um
+// salto em linha reta que só para em pontos de salto: células onde o caminho
+// pode precisar virar. Os saltos ao longo de Y seguem retos; os ao longo de X
+// sondam Y a cada passo, de modo que todo caminho canônico vira de X para Y.
+//
+// Células intransponíveis e a borda do mapa são os obstáculos do JPS
+// clássico. Além dos pontos de salto clássicos, o salto para nas casas, no
+// grande mestre e na fronteira da região (células vizinhas de um terreno
+// transitável de outro custo ou de um marco), por onde um caminho pode sair
+// dela. Ali a expansão volta a ser a normal, e batalhas, ordem zodiacal e
+// custos seguem as regras da busca. Regiões cercadas de terreno transitável
+// têm fronteira em toda a volta e rendem poucos saltos; as cercadas de
+// muralhas são as que mais ganham. Os saltos só existem no movimento
+// ortogonal; nos demais buscaMelhorPrimeiro os desliga e expande os vizinhos
+// de um passo como o A*.
+
+// uniforme diz se a célula pertence à região de custo c: transitável, com o
+// mesmo custo e sem marco.
+func (g *Game) uniforme(gr grade, p Point, c int) bool {
+	if !g.posicaoValida(p) {
+		return false
+	}
+	celula := gr.celula(p)
+	return gr.custo[celula] == c && gr.casa[celula] < 0 && p != g.GrandeMestre
+}
+
+// pontoDeParada diz se o salto que chega a p, na região de custo c, deve
+// parar ali: marcos e células na fronteira da região.
+func (g *Game) pontoDeParada(gr grade, p Point, c int) bool {
+	if gr.casa[gr.celula(p)] >= 0 || p == g.GrandeMestre {
+		return true
+	}
+	for _, d := range DIRECOES_ORTOGONAIS {
+		v := Point{p.X + d.X, p.Y + d.Y}
+		if g.posicaoValida(v) && gr.custo[gr.celula(v)] >= 0 && !g.uniforme(gr, v, c) {
+			return true
+		}
+	}
+	return false
+}
+
+// saltar anda de p na direção d e devolve o ponto de salto e o número de
+// passos; falso se a reta termina num beco sem interesse.
+func (g *Game) saltar(gr grade, p, d Point) (Point, int, bool) {
+	if d.X == 0 {
+		passos, achou := g.saltarEmY(gr, p, d.Y)
+		return Point{p.X, p.Y + passos*d.Y}, passos, achou
+	}
+
+	n := Point{p.X + d.X, p.Y}
+	if !g.posicaoValida(n) || gr.custo[gr.celula(n)] < 0 {
+		return n, 0, false
+	}
+	c := gr.custo[gr.celula(n)]
+	livre := func(x, y int) bool { return g.uniforme(gr, Point{x, y}, c) }
+
+	for passos := 1; ; passos++ {
+		if g.pontoDeParada(gr, n, c) {
+			return n, passos, true
+		}
+		// Vizinho forçado quando um lado se abre logo após um obstáculo
+		if (livre(n.X, n.Y-1) && !livre(n.X-d.X, n.Y-1)) || (livre(n.X, n.Y+1) && !livre(n.X-d.X, n.Y+1)) {
+			return n, passos, true
+		}
+		// Para onde uma sonda em Y acha um ponto de salto
+		for _, sentido := range [2]int{-1, 1} {
+			if _, achou := g.saltarEmY(gr, n, sentido); achou {
+				return n, passos, true
+			}
+		}
+
+		proximo := Point{n.X + d.X, n.Y}
+		if !g.posicaoValida(proximo) || !g.uniforme(gr, proximo, c) {
+			return n, 0, false
+		}
+		n = proximo
+	}
+}
+
+// saltarEmY é o salto ao longo de Y, no sentido dy. As sondas dos saltos em X
+// repetem as mesmas retas muitas vezes, então o resultado de cada célula de
+// partida fica em gr.saltosY: passos até o ponto de salto, -1 para beco ou
+// zero se ainda não calculado. Toda célula da reta compartilha o mesmo fim.
+func (g *Game) saltarEmY(gr grade, p Point, dy int) (int, bool) {
+	indice := func(q Point) int {
+		if dy > 0 {
+			return 2*gr.celula(q) + 1
+		}
+		return 2 * gr.celula(q)
+	}
+
+	passos, andadas, achou := 0, 0, false
+	for s := p; ; s.Y += dy {
+		if gr.saltosY != nil {
+			if memo := gr.saltosY[indice(s)]; memo != 0 {
+				passos, achou = passos+int(memo), memo > 0
+				break
+			}
+		}
+		// Só a primeira célula pode ter outro custo: se n for de outra região,
+		// s já era um ponto de parada
+		n := Point{s.X, s.Y + dy}
+		if !g.posicaoValida(n) || gr.custo[gr.celula(n)] < 0 {
+			break
+		}
+		passos++
+		andadas++
+		c := gr.custo[gr.celula(n)]
+		livre := func(x, y int) bool { return g.uniforme(gr, Point{x, y}, c) }
+		if g.pontoDeParada(gr, n, c) ||
+			(livre(n.X-1, n.Y) && !livre(n.X-1, n.Y-dy)) || (livre(n.X+1, n.Y) && !livre(n.X+1, n.Y-dy)) {
+			achou = true
+			break
+		}
+	}
+
+	if gr.saltosY != nil {
+		for i, s := 0, p; i < andadas; i, s.Y = i+1, s.Y+dy {
+			if achou {
+				gr.saltosY[indice(s)] = int32(passos - i)
+			} else {
+				gr.saltosY[indice(s)] = -1
+			}
+		}
+	}
+	return passos, achou
+}
+
+// saltosEm troca os vizinhos de atual pelos pontos de salto nas mesmas
+// direções. A direção de volta é podada, salvo depois de uma batalha, quando
+// voltar pelo mesmo corredor pode ser o único caminho.
+func (g *Game) saltosEm(gr grade, atual *Node, vizinhos []Point) []Point {
+	var volta Point
+	if pai := atual.Parent; pai != nil && pai.Visited == atual.Visited {
+		volta = Point{sinal(pai.X - atual.X), sinal(pai.Y - atual.Y)}
+	}
+
+	saltos := vizinhos[:0]
+	for _, v := range vizinhos {
+		d := Point{v.X - atual.X, v.Y - atual.Y}
+		if d == volta {
+			continue
+		}
+		if ponto, _, achou := g.saltar(gr, atual.Point, d); achou {
+			saltos = append(saltos, ponto)
+		}
+	}
+	return saltos
+}
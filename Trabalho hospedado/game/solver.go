@@ -0,0 +1,303 @@
+package game
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// ---------------- Algoritmos de busca ----------------
+// Todos os algoritmos percorrem o mesmo espaço de estados e devolvem o mesmo
+// ResultadoBusca, com CustoTotal sempre medido pelos custos reais do caminho,
+// para que possam ser comparados diretamente.
+
+const (
+	ALGORITMO_ASTAR           = "astar"
+	ALGORITMO_ASTAR_PONDERADO = "astar_ponderado"
+	ALGORITMO_DIJKSTRA        = "dijkstra"
+	ALGORITMO_GULOSA          = "gulosa"
+	ALGORITMO_LARGURA         = "largura"
+	ALGORITMO_THETA           = "theta"
+	ALGORITMO_JPS             = "jps"
+
+	PESO_PADRAO = 1.5
+)
+
+type Solver interface {
+	Nome() string
+	Resolver(ctx context.Context, g *Game, opcoes OpcoesBusca) ResultadoBusca
+}
+
+var SOLVERS = map[string]Solver{
+	ALGORITMO_ASTAR:           AEstrela{},
+	ALGORITMO_ASTAR_PONDERADO: AEstrelaPonderado{},
+	ALGORITMO_DIJKSTRA:        Dijkstra{},
+	ALGORITMO_GULOSA:          BuscaGulosa{},
+	ALGORITMO_LARGURA:         BuscaLargura{},
+	ALGORITMO_HELD_KARP:       HeldKarp{},
+	ALGORITMO_THETA:           ThetaEstrela{},
+	ALGORITMO_JPS:             SaltoDePontos{},
+}
+
+func ObterSolver(nome string) (Solver, error) {
+	if nome == "" {
+		nome = ALGORITMO_ASTAR
+	}
+	solver, existe := SOLVERS[nome]
+	if !existe {
+		return nil, fmt.Errorf("algoritmo desconhecido %q (disponíveis: %v)", nome, NomesSolvers())
+	}
+	return solver, nil
+}
+
+func NomesSolvers() []string {
+	nomes := make([]string, 0, len(SOLVERS))
+	for nome := range SOLVERS {
+		nomes = append(nomes, nome)
+	}
+	sort.Strings(nomes)
+	return nomes
+}
+
+// Buscar resolve o jogo com o algoritmo escolhido em opcoes.Algoritmo.
+func (g *Game) Buscar(opcoes OpcoesBusca) ResultadoBusca {
+	return g.BuscarComContexto(context.Background(), opcoes)
+}
+
+// BuscarComContexto é Buscar interrompível: se ctx for cancelado ou um dos
+// orçamentos de opcoes se esgotar, devolve o resultado parcial.
+func (g *Game) BuscarComContexto(ctx context.Context, opcoes OpcoesBusca) ResultadoBusca {
+	if opcoes.Modo == "" {
+		opcoes.Modo = MODO_LIVRE
+	}
+	if opcoes.Objetivo == "" {
+		opcoes.Objetivo = OBJETIVO_TODAS_CASAS
+	}
+	if opcoes.Algoritmo == "" {
+		opcoes.Algoritmo = ALGORITMO_ASTAR
+		if opcoes.Objetivo == OBJETIVO_MAXIMO_CASAS {
+			opcoes.Algoritmo = ALGORITMO_HELD_KARP
+		}
+	}
+	if opcoes.Prazo == 0 {
+		opcoes.Prazo = PRAZO_PADRAO
+	}
+	if opcoes.Heuristica == "" {
+		opcoes.Heuristica = HEURISTICA_PADRAO
+	}
+
+	if err := opcoes.Validar(); err != nil {
+		return ResultadoBusca{
+			Sucesso:    false,
+			Motivo:     err.Error(),
+			Modo:       opcoes.Modo,
+			Algoritmo:  opcoes.Algoritmo,
+			Heuristica: opcoes.Heuristica,
+			Objetivo:   opcoes.Objetivo,
+			Prazo:      opcoes.Prazo,
+			Duracao:    time.Duration(0).String(),
+		}
+	}
+
+	if opcoes.LimiteTempo > 0 {
+		var cancelar context.CancelFunc
+		ctx, cancelar = context.WithTimeout(ctx, opcoes.LimiteTempo)
+		defer cancelar()
+	}
+
+	solver, _ := ObterSolver(opcoes.Algoritmo)
+	resultado := solver.Resolver(ctx, g, opcoes)
+	resultado.Objetivo = opcoes.Objetivo
+	resultado.Prazo = opcoes.Prazo
+	resultado.DentroDoPrazo = resultado.Sucesso && resultado.CustoTotal <= opcoes.Prazo
+	return resultado
+}
+
+type estrategiaBusca struct {
+	prioridade func(g, h, passos int) int
+	reabrir    bool
+	// qualquerAngulo liga nós ao avô quando há linha de visada (Theta*)
+	qualquerAngulo bool
+	// saltos troca os vizinhos por pontos de salto (JPS, ver saltos.go)
+	saltos bool
+	// otima diz se o algoritmo garante o ótimo com a heurística usada
+	otima func(h Heuristica) bool
+}
+
+func nuncaOtima(Heuristica) bool { return false }
+
+type AEstrela struct{}
+
+func (AEstrela) Nome() string { return ALGORITMO_ASTAR }
+
+func (AEstrela) Resolver(ctx context.Context, g *Game, opcoes OpcoesBusca) ResultadoBusca {
+	return g.buscaMelhorPrimeiro(ctx, opcoes, estrategiaBusca{
+		prioridade: func(custo, h, _ int) int { return custo + h },
+		reabrir:    true,
+		otima:      Heuristica.Admissivel,
+	})
+}
+
+// ThetaEstrela é o A* em qualquer ângulo. Os atalhos são caminhos de células
+// válidos cobrados pelo custo real e as arestas do A* continuam disponíveis,
+// então o custo ótimo é o mesmo do A*; muda o desenho, com menos vértices.
+type ThetaEstrela struct{}
+
+func (ThetaEstrela) Nome() string { return ALGORITMO_THETA }
+
+func (ThetaEstrela) Resolver(ctx context.Context, g *Game, opcoes OpcoesBusca) ResultadoBusca {
+	return g.buscaMelhorPrimeiro(ctx, opcoes, estrategiaBusca{
+		prioridade:     func(custo, h, _ int) int { return custo + h },
+		reabrir:        true,
+		qualquerAngulo: true,
+		otima:          Heuristica.Admissivel,
+	})
+}
+
+// SaltoDePontos é o A* com Jump Point Search nas regiões uniformes. A poda
+// só descarta caminhos simétricos de mesmo custo, então o ótimo é o do A*.
+type SaltoDePontos struct{}
+
+func (SaltoDePontos) Nome() string { return ALGORITMO_JPS }
+
+func (SaltoDePontos) Resolver(ctx context.Context, g *Game, opcoes OpcoesBusca) ResultadoBusca {
+	return g.buscaMelhorPrimeiro(ctx, opcoes, estrategiaBusca{
+		prioridade: func(custo, h, _ int) int { return custo + h },
+		reabrir:    true,
+		saltos:     true,
+		otima:      Heuristica.Admissivel,
+	})
+}
+
+// AEstrelaPonderado usa f = g + w·h (opcoes.Peso, padrão 1.5)
+type AEstrelaPonderado struct{}
+
+func (AEstrelaPonderado) Nome() string { return ALGORITMO_ASTAR_PONDERADO }
+
+func (AEstrelaPonderado) Resolver(ctx context.Context, g *Game, opcoes OpcoesBusca) ResultadoBusca {
+	peso := opcoes.Peso
+	if peso == 0 {
+		peso = PESO_PADRAO
+	}
+	return g.buscaMelhorPrimeiro(ctx, opcoes, estrategiaBusca{
+		prioridade: func(custo, h, _ int) int { return custo + int(math.Round(peso*float64(h))) },
+		reabrir:    true,
+		otima: func(h Heuristica) bool {
+			return peso == 1 && h.Admissivel()
+		},
+	})
+}
+
+// Dijkstra é a busca de custo uniforme: ignora a heurística
+type Dijkstra struct{}
+
+func (Dijkstra) Nome() string { return ALGORITMO_DIJKSTRA }
+
+func (Dijkstra) Resolver(ctx context.Context, g *Game, opcoes OpcoesBusca) ResultadoBusca {
+	return g.buscaMelhorPrimeiro(ctx, opcoes, estrategiaBusca{
+		prioridade: func(custo, _, _ int) int { return custo },
+		reabrir:    true,
+		otima:      func(Heuristica) bool { return true },
+	})
+}
+
+// BuscaGulosa expande sempre o nó de menor heurística, sem reabrir estados
+type BuscaGulosa struct{}
+
+func (BuscaGulosa) Nome() string { return ALGORITMO_GULOSA }
+
+func (BuscaGulosa) Resolver(ctx context.Context, g *Game, opcoes OpcoesBusca) ResultadoBusca {
+	return g.buscaMelhorPrimeiro(ctx, opcoes, estrategiaBusca{
+		prioridade: func(_, h, _ int) int { return h },
+		otima:      nuncaOtima,
+	})
+}
+
+// BuscaLargura expande por número de passos: encontra o caminho com menos
+// passos, não o de menor custo
+type BuscaLargura struct{}
+
+func (BuscaLargura) Nome() string { return ALGORITMO_LARGURA }
+
+func (BuscaLargura) Resolver(ctx context.Context, g *Game, opcoes OpcoesBusca) ResultadoBusca {
+	return g.buscaMelhorPrimeiro(ctx, opcoes, estrategiaBusca{
+		prioridade: func(_, _, passos int) int { return passos },
+		otima:      nuncaOtima,
+	})
+}
+
+// ---------------- Interrupção ----------------
+const (
+	ABORTADA_CANCELADA    = "cancelada"
+	ABORTADA_LIMITE_NOS   = "limite_nos"
+	ABORTADA_LIMITE_TEMPO = "limite_tempo"
+
+	// O contexto é consultado a cada tantos nós expandidos
+	INTERVALO_VERIFICACAO = 256
+)
+
+// orcamento acompanha os limites de uma busca. Leva também OpcoesBusca.Rastrear
+// para as etapas que, como as do held_karp, só recebem os limites.
+type orcamento struct {
+	ctx       context.Context
+	maximoNos int
+	rastrear  func(PassoRastro)
+}
+
+func novoOrcamento(ctx context.Context, opcoes OpcoesBusca) orcamento {
+	return orcamento{ctx: ctx, maximoNos: opcoes.MaximoNos, rastrear: opcoes.Rastrear}
+}
+
+// registrar repassa o passo ao rastro, quando há um.
+func (o orcamento) registrar(passo PassoRastro) {
+	if o.rastrear != nil {
+		o.rastrear(passo)
+	}
+}
+
+// noTrecho devolve os mesmos limites com o rastro deslocado para um trecho
+// da rota: os passos somam as casas já conquistadas e o custo acumulado até
+// a origem do trecho.
+func (o orcamento) noTrecho(casas uint64, custo int) orcamento {
+	if o.rastrear == nil {
+		return o
+	}
+	rastrear := o.rastrear
+	o.rastrear = func(passo PassoRastro) {
+		passo.Casas |= casas
+		passo.G += custo
+		passo.F += custo
+		rastrear(passo)
+	}
+	return o
+}
+
+// esgotado devolve o motivo da interrupção (ABORTADA_*) ou "" para seguir.
+func (o orcamento) esgotado(expandidos int) string {
+	if o.maximoNos > 0 && expandidos >= o.maximoNos {
+		return ABORTADA_LIMITE_NOS
+	}
+	if expandidos%INTERVALO_VERIFICACAO != 0 {
+		return ""
+	}
+	switch err := o.ctx.Err(); {
+	case errors.Is(err, context.DeadlineExceeded):
+		return ABORTADA_LIMITE_TEMPO
+	case err != nil:
+		return ABORTADA_CANCELADA
+	}
+	return ""
+}
+
+func descreverInterrupcao(motivo string, expandidos int) string {
+	switch motivo {
+	case ABORTADA_LIMITE_NOS:
+		return fmt.Sprintf("busca abortada: limite de %d nós expandidos atingido", expandidos)
+	case ABORTADA_LIMITE_TEMPO:
+		return fmt.Sprintf("busca abortada: limite de tempo atingido após %d nós expandidos", expandidos)
+	}
+	return fmt.Sprintf("busca abortada: cancelada após %d nós expandidos", expandidos)
+}
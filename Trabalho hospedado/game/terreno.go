@@ -0,0 +1,126 @@
+package game
+
+import "fmt"
+
+// ---------------- Terrenos e sobreposição ----------------
+// O mapa guarda em cada célula só o índice do terreno no registro
+// Game.Terrenos. Entrada, grande mestre e casas ficam numa camada separada
+// (Game.Sobreposicao), montada a partir das posições.
+
+type TipoTerreno struct {
+	Nome  string `json:"nome"`
+	Custo int    `json:"custo"`
+	Cor   string `json:"cor,omitempty"`
+	// Simbolo representa o terreno no mapa em texto
+	Simbolo string `json:"simbolo,omitempty"`
+	// Intransponivel marca paredes, lava etc.: a célula não pode ser pisada
+	Intransponivel bool `json:"intransponivel,omitempty"`
+}
+
+// O gerador de mapas usa MONTANHOSO, PLANO e ROCHOSO: registros
+// personalizados mantêm esses três nos índices 0 a 2
+var TERRENOS_PADRAO = []TipoTerreno{
+	MONTANHOSO: {Nome: "montanhoso", Custo: 200, Cor: "#444444", Simbolo: "M"},
+	PLANO:      {Nome: "plano", Custo: 1, Cor: "#888888", Simbolo: "."},
+	ROCHOSO:    {Nome: "rochoso", Custo: 5, Cor: "#666666", Simbolo: "R"},
+}
+
+// Valores da camada de sobreposição
+const (
+	SEM_MARCO     = 0
+	ENTRADA       = 1
+	GRANDE_MESTRE = 2
+	CASA_ZODIACO  = 3 // CASA_ZODIACO + i marca a casa i
+)
+
+// No formato antigo os marcos eram gravados no próprio mapa, a partir de 3
+const MARCO_LEGADO = 3
+
+func terrenosPadrao() []TipoTerreno {
+	return append([]TipoTerreno(nil), TERRENOS_PADRAO...)
+}
+
+func indiceTerreno(terrenos []TipoTerreno, nome string) int {
+	for i, terreno := range terrenos {
+		if terreno.Nome == nome {
+			return i
+		}
+	}
+	return -1
+}
+
+// mesclarTerrenos substitui os terrenos de mesmo nome e acrescenta os novos
+// ao final, preservando os índices já usados no mapa.
+func mesclarTerrenos(base, extras []TipoTerreno) []TipoTerreno {
+	terrenos := append([]TipoTerreno(nil), base...)
+	for _, extra := range extras {
+		if i := indiceTerreno(terrenos, extra.Nome); i >= 0 {
+			terrenos[i] = extra
+		} else {
+			terrenos = append(terrenos, extra)
+		}
+	}
+	return terrenos
+}
+
+func validarTerrenos(terrenos []TipoTerreno, campo string, erros *ErrosValidacao) {
+	vistos := make(map[string]bool, len(terrenos))
+	for i, terreno := range terrenos {
+		campoTerreno := fmt.Sprintf("%s[%d]", campo, i)
+		switch {
+		case terreno.Nome == "":
+			erros.adicionar(campoTerreno+".nome", "nome do terreno não informado")
+		case vistos[terreno.Nome]:
+			erros.adicionar(campoTerreno+".nome", "terreno %s repetido", terreno.Nome)
+		}
+		vistos[terreno.Nome] = true
+		if terreno.Custo < 0 && !terreno.Intransponivel {
+			erros.adicionar(campoTerreno+".custo", "custo do terreno %s não pode ser negativo", terreno.Nome)
+		}
+	}
+}
+
+// marcarPosicoes monta a camada de sobreposição a partir das posições.
+func (g *Game) marcarPosicoes() {
+	g.Sobreposicao = make([][]int, g.Size)
+	for i := range g.Sobreposicao {
+		g.Sobreposicao[i] = make([]int, g.Size)
+	}
+
+	if g.posicaoValida(g.Entrada) {
+		g.Sobreposicao[g.Entrada.X][g.Entrada.Y] = ENTRADA
+	}
+	if g.posicaoValida(g.GrandeMestre) {
+		g.Sobreposicao[g.GrandeMestre.X][g.GrandeMestre.Y] = GRANDE_MESTRE
+	}
+	for i, casa := range g.Casas {
+		if g.posicaoValida(casa.Posicao) {
+			g.Sobreposicao[casa.Posicao.X][casa.Posicao.Y] = CASA_ZODIACO + i
+		}
+	}
+}
+
+// migrarFormatoLegado converte jogos sem registro de terrenos: custos_terreno
+// ajusta os custos padrão e os marcos gravados no mapa viram terreno PLANO,
+// que era o custo cobrado neles.
+func (g *Game) migrarFormatoLegado() {
+	g.Terrenos = terrenosPadrao()
+	for terreno, custo := range g.CustosTerreno {
+		if terreno >= 0 && terreno < len(g.Terrenos) {
+			g.Terrenos[terreno].Custo = custo
+		}
+	}
+	g.CustosTerreno = nil
+
+	for _, linha := range g.Mapa {
+		for y, terreno := range linha {
+			if terreno >= MARCO_LEGADO {
+				linha[y] = PLANO
+			}
+		}
+	}
+}
+
+func (g *Game) transitavel(p Point) bool {
+	return !g.Terrenos[g.Mapa[p.X][p.Y]].Intransponivel
+}
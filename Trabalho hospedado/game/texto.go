@@ -0,0 +1,362 @@
+package game
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ---------------- Mapa em texto ----------------
+// Formato para editar mapas à mão. Um cabeçalho de diretivas, uma por linha
+// (linhas vazias e iniciadas por # são ignoradas), e depois da linha "mapa"
+// uma linha da grade por x, um caractere por y:
+//
+//	terreno <símbolo> <custo | x> <cor | -> <nome>   (custo x: intransponível)
+//	cavaleiro <poder cósmico> <energia> <nome>
+//	casa <letra> <dificuldade> <nome>
+//	sob <marco> <símbolo>   (terreno sob o marco, se não for plano)
+//	movimento <tipo> [custo diagonal] [cantos]   (sem ela, ortogonal)
+//	mapa
+//
+// Na grade, E é a entrada, G o grande mestre e as letras minúsculas a, b, c...
+// são as casas na ordem (as maiúsculas A a L colidiriam com E e G). Sem
+// diretivas "terreno" valem M montanhoso, . plano e R rochoso; sem
+// "cavaleiro", os cavaleiros de NovoJogo; sem "casa", as casas de NovoJogo
+// de mesma letra.
+
+const (
+	SIMBOLO_ENTRADA       = 'E'
+	SIMBOLO_GRANDE_MESTRE = 'G'
+	SIMBOLO_PRIMEIRA_CASA = 'a'
+
+	MAXIMO_CASAS_TEXTO = 26
+
+	// Símbolos livres para terrenos sem um símbolo próprio utilizável
+	SIMBOLOS_TERRENO = "MR.#~^%*+=&@$0123456789ABCDFHIJKLNOPQSTUVWXYZ"
+)
+
+func simboloCasa(i int) rune {
+	return SIMBOLO_PRIMEIRA_CASA + rune(i)
+}
+
+func casaDoSimbolo(s rune) (int, bool) {
+	i := int(s - SIMBOLO_PRIMEIRA_CASA)
+	return i, i >= 0 && i < MAXIMO_CASAS_TEXTO
+}
+
+// simboloTerrenoValido diz se s pode representar um terreno na grade.
+func simboloTerrenoValido(s rune) bool {
+	_, casa := casaDoSimbolo(s)
+	return !casa && s != SIMBOLO_ENTRADA && s != SIMBOLO_GRANDE_MESTRE &&
+		s <= unicode.MaxASCII && unicode.IsGraphic(s) && !unicode.IsSpace(s)
+}
+
+// LerMapaTexto monta um Game a partir do formato em texto e o valida. Erros
+// de formato apontam a linha; os de validação, os campos do Game.
+func LerMapaTexto(r io.Reader) (*Game, error) {
+	var erros ErrosValidacao
+	g := &Game{}
+	simbolos := make(map[rune]int)
+	casas := make(map[int]CasaZodiaco)
+	sob := make(map[rune]rune)
+	var grade []string
+	linhaMapa := 0
+
+	leitor := bufio.NewScanner(r)
+	leitor.Buffer(nil, TAMANHO_MAXIMO_CENARIO)
+	for n := 1; leitor.Scan(); n++ {
+		linha := strings.TrimRight(leitor.Text(), " \t\r")
+		campo := fmt.Sprintf("linha %d", n)
+
+		if linhaMapa > 0 {
+			if linha != "" {
+				grade = append(grade, linha)
+			}
+			continue
+		}
+		if linha == "" || strings.HasPrefix(linha, "#") {
+			continue
+		}
+
+		partes := strings.Fields(linha)
+		switch diretiva := partes[0]; {
+		case diretiva == "mapa" && len(partes) == 1:
+			linhaMapa = n
+
+		case diretiva == "terreno" && len(partes) >= 5:
+			simbolo := []rune(partes[1])
+			terreno := TipoTerreno{Nome: strings.Join(partes[4:], " "), Simbolo: partes[1]}
+			if len(simbolo) != 1 || !simboloTerrenoValido(simbolo[0]) {
+				erros.adicionar(campo, "símbolo de terreno inválido %q", partes[1])
+				continue
+			}
+			if _, repetido := simbolos[simbolo[0]]; repetido {
+				erros.adicionar(campo, "símbolo %q repetido", partes[1])
+				continue
+			}
+			if partes[2] == "x" {
+				terreno.Intransponivel = true
+			} else if custo, err := strconv.Atoi(partes[2]); err == nil {
+				terreno.Custo = custo
+			} else {
+				erros.adicionar(campo, "custo inválido %q", partes[2])
+			}
+			if partes[3] != "-" {
+				terreno.Cor = partes[3]
+			}
+			simbolos[simbolo[0]] = len(g.Terrenos)
+			g.Terrenos = append(g.Terrenos, terreno)
+
+		case diretiva == "cavaleiro" && len(partes) >= 4:
+			poder, errPoder := strconv.ParseFloat(partes[1], 64)
+			energia, errEnergia := strconv.Atoi(partes[2])
+			if errPoder != nil || errEnergia != nil {
+				erros.adicionar(campo, "esperado: cavaleiro <poder cósmico> <energia> <nome>")
+				continue
+			}
+			g.Cavaleiros = append(g.Cavaleiros, CavaleiroBronze{Nome: strings.Join(partes[3:], " "), PoderCosmico: poder, Energia: energia})
+
+		case diretiva == "casa" && len(partes) >= 4:
+			letra := []rune(partes[1])
+			dificuldade, err := strconv.Atoi(partes[2])
+			if len(letra) != 1 || err != nil {
+				erros.adicionar(campo, "esperado: casa <letra> <dificuldade> <nome>")
+				continue
+			}
+			i, ok := casaDoSimbolo(letra[0])
+			if !ok {
+				erros.adicionar(campo, "casa %q: use uma letra minúscula", partes[1])
+				continue
+			}
+			if _, repetida := casas[i]; repetida {
+				erros.adicionar(campo, "casa %q repetida", partes[1])
+				continue
+			}
+			casas[i] = CasaZodiaco{Nome: strings.Join(partes[3:], " "), Dificuldade: dificuldade}
+
+		case diretiva == "sob" && len(partes) == 3:
+			marco, terreno := []rune(partes[1]), []rune(partes[2])
+			if len(marco) != 1 || len(terreno) != 1 {
+				erros.adicionar(campo, "esperado: sob <marco> <símbolo>")
+				continue
+			}
+			if _, casa := casaDoSimbolo(marco[0]); !casa && marco[0] != SIMBOLO_ENTRADA && marco[0] != SIMBOLO_GRANDE_MESTRE {
+				erros.adicionar(campo, "marco %q desconhecido", partes[1])
+				continue
+			}
+			sob[marco[0]] = terreno[0]
+
+		case diretiva == "movimento" && len(partes) >= 2 && len(partes) <= 4:
+			g.Movimento.Tipo = partes[1]
+			if len(partes) >= 3 {
+				custo, err := strconv.ParseFloat(partes[2], 64)
+				if err != nil {
+					erros.adicionar(campo, "custo diagonal inválido %q", partes[2])
+				}
+				g.Movimento.CustoDiagonal = custo
+			}
+			if len(partes) == 4 {
+				g.Movimento.Cantos = partes[3]
+			}
+
+		default:
+			erros.adicionar(campo, "diretiva desconhecida ou incompleta %q", linha)
+		}
+	}
+	if err := leitor.Err(); err != nil {
+		return nil, err
+	}
+	if linhaMapa == 0 {
+		erros.adicionar("mapa", "linha \"mapa\" não encontrada")
+	}
+	if len(erros) > 0 {
+		return nil, erros
+	}
+
+	if len(g.Terrenos) == 0 {
+		g.Terrenos = terrenosPadrao()
+		for i, terreno := range g.Terrenos {
+			simbolos[[]rune(terreno.Simbolo)[0]] = i
+		}
+	}
+	if len(g.Cavaleiros) == 0 {
+		g.Cavaleiros = cavaleirosPadrao()
+	}
+
+	// Terreno sob os marcos: PLANO, salvo diretiva "sob"
+	terrenoSob := func(marco rune, campo string) int {
+		simbolo, existe := sob[marco]
+		if !existe {
+			return PLANO
+		}
+		terreno, existe := simbolos[simbolo]
+		if !existe {
+			erros.adicionar(campo, "sob %c: terreno %q desconhecido", marco, simbolo)
+		}
+		return terreno
+	}
+
+	g.Size = len(grade)
+	g.Mapa = make([][]int, g.Size)
+	posicoes := make(map[rune]Point)
+	for x, linha := range grade {
+		campo := fmt.Sprintf("linha %d", linhaMapa+1+x)
+		celulas := []rune(linha)
+		if len(celulas) != g.Size {
+			erros.adicionar(campo, "esperadas %d colunas, recebidas %d", g.Size, len(celulas))
+			continue
+		}
+		g.Mapa[x] = make([]int, g.Size)
+		for y, simbolo := range celulas {
+			if terreno, existe := simbolos[simbolo]; existe {
+				g.Mapa[x][y] = terreno
+				continue
+			}
+			_, casa := casaDoSimbolo(simbolo)
+			if !casa && simbolo != SIMBOLO_ENTRADA && simbolo != SIMBOLO_GRANDE_MESTRE {
+				erros.adicionar(campo, "coluna %d: símbolo %q desconhecido", y+1, simbolo)
+				continue
+			}
+			if anterior, repetido := posicoes[simbolo]; repetido {
+				erros.adicionar(campo, "coluna %d: %c repetido (já em %d, %d)", y+1, simbolo, anterior.X, anterior.Y)
+				continue
+			}
+			posicoes[simbolo] = Point{x, y}
+			g.Mapa[x][y] = terrenoSob(simbolo, campo)
+		}
+	}
+
+	var existe bool
+	if g.Entrada, existe = posicoes[SIMBOLO_ENTRADA]; !existe {
+		erros.adicionar("mapa", "entrada (%c) não encontrada", SIMBOLO_ENTRADA)
+	}
+	if g.GrandeMestre, existe = posicoes[SIMBOLO_GRANDE_MESTRE]; !existe {
+		erros.adicionar("mapa", "grande mestre (%c) não encontrado", SIMBOLO_GRANDE_MESTRE)
+	}
+
+	// As casas seguem a ordem das letras, sem buracos
+	for i := 0; i < MAXIMO_CASAS_TEXTO; i++ {
+		posicao, naGrade := posicoes[simboloCasa(i)]
+		casa, declarada := casas[i]
+		switch {
+		case !naGrade && declarada:
+			erros.adicionar("mapa", "casa %c declarada mas ausente da grade", simboloCasa(i))
+		case naGrade && len(g.Casas) < i:
+			erros.adicionar("mapa", "casa %c sem a casa %c antes dela", simboloCasa(i), simboloCasa(len(g.Casas)))
+		case naGrade && !declarada && i >= len(CASAS_PADRAO):
+			erros.adicionar("mapa", "casa %c sem diretiva \"casa\"", simboloCasa(i))
+		case naGrade:
+			if !declarada {
+				casa = CASAS_PADRAO[i]
+			}
+			casa.Posicao = posicao
+			g.Casas = append(g.Casas, casa)
+		}
+	}
+
+	if len(erros) > 0 {
+		return nil, erros
+	}
+	if err := g.normalizar(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// simbolosTerreno escolhe um símbolo por terreno: o próprio, quando válido e
+// livre, ou o primeiro livre de SIMBOLOS_TERRENO.
+func (g *Game) simbolosTerreno() ([]rune, error) {
+	simbolos := make([]rune, len(g.Terrenos))
+	usados := make(map[rune]bool)
+	for i, terreno := range g.Terrenos {
+		proprio := []rune(terreno.Simbolo)
+		if len(proprio) == 1 && simboloTerrenoValido(proprio[0]) && !usados[proprio[0]] {
+			simbolos[i] = proprio[0]
+			usados[proprio[0]] = true
+		}
+	}
+
+	livres := []rune(SIMBOLOS_TERRENO)
+	for i := range simbolos {
+		for simbolos[i] == 0 {
+			if len(livres) == 0 {
+				return nil, fmt.Errorf("no máximo %d terrenos no mapa em texto", len(SIMBOLOS_TERRENO))
+			}
+			if !usados[livres[0]] {
+				simbolos[i] = livres[0]
+				usados[livres[0]] = true
+			}
+			livres = livres[1:]
+		}
+	}
+	return simbolos, nil
+}
+
+// EscreverMapaTexto grava o jogo no formato em texto. Ler o resultado com
+// LerMapaTexto devolve o mesmo jogo, exceto o custo de terrenos
+// intransponíveis, que nunca é cobrado.
+func (g *Game) EscreverMapaTexto(w io.Writer) error {
+	if len(g.Casas) > MAXIMO_CASAS_TEXTO {
+		return fmt.Errorf("o mapa em texto comporta no máximo %d casas, recebidas %d", MAXIMO_CASAS_TEXTO, len(g.Casas))
+	}
+	simbolos, err := g.simbolosTerreno()
+	if err != nil {
+		return err
+	}
+
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "# Cavaleiros do Zodíaco: %c entrada, %c grande mestre, %c-%c casas\n",
+		SIMBOLO_ENTRADA, SIMBOLO_GRANDE_MESTRE, simboloCasa(0), simboloCasa(MAXIMO_CASAS_TEXTO-1))
+	for i, terreno := range g.Terrenos {
+		custo, cor := strconv.Itoa(terreno.Custo), terreno.Cor
+		if terreno.Intransponivel {
+			custo = "x"
+		}
+		if cor == "" {
+			cor = "-"
+		}
+		fmt.Fprintf(b, "terreno %c %s %s %s\n", simbolos[i], custo, cor, terreno.Nome)
+	}
+	for _, cavaleiro := range g.Cavaleiros {
+		fmt.Fprintf(b, "cavaleiro %s %d %s\n", strconv.FormatFloat(cavaleiro.PoderCosmico, 'g', -1, 64), cavaleiro.Energia, cavaleiro.Nome)
+	}
+	for i, casa := range g.Casas {
+		fmt.Fprintf(b, "casa %c %d %s\n", simboloCasa(i), casa.Dificuldade, casa.Nome)
+	}
+	switch m := g.Movimento; m.Tipo {
+	case "", MOVIMENTO_ORTOGONAL:
+	case MOVIMENTO_DIAGONAL:
+		fmt.Fprintf(b, "movimento %s %s %s\n", m.Tipo, strconv.FormatFloat(m.CustoDiagonal, 'g', -1, 64), m.Cantos)
+	default:
+		fmt.Fprintf(b, "movimento %s\n", m.Tipo)
+	}
+
+	grade := make([][]rune, g.Size)
+	for x, linha := range g.Mapa {
+		grade[x] = make([]rune, g.Size)
+		for y, terreno := range linha {
+			grade[x][y] = simbolos[terreno]
+		}
+	}
+	marcar := func(p Point, marco rune) {
+		if terreno := g.Mapa[p.X][p.Y]; terreno != PLANO {
+			fmt.Fprintf(b, "sob %c %c\n", marco, simbolos[terreno])
+		}
+		grade[p.X][p.Y] = marco
+	}
+	marcar(g.Entrada, SIMBOLO_ENTRADA)
+	marcar(g.GrandeMestre, SIMBOLO_GRANDE_MESTRE)
+	for i, casa := range g.Casas {
+		marcar(casa.Posicao, simboloCasa(i))
+	}
+
+	b.WriteString("mapa\n")
+	for _, linha := range grade {
+		b.WriteString(string(linha))
+		b.WriteByte('\n')
+	}
+	return b.Flush()
+}
@@ -0,0 +1,126 @@
+package game
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var atualizar = flag.Bool("atualizar", false, "regrava os arquivos de testdata")
+
+// Todo mapa em testdata é golden de si mesmo: ler e escrever de novo devolve
+// os mesmos bytes.
+func TestMapaTextoIdaEVolta(t *testing.T) {
+	arquivos, err := filepath.Glob(filepath.Join("testdata", "*.txt"))
+	if err != nil || len(arquivos) == 0 {
+		t.Fatalf("nenhum mapa em testdata: %v", err)
+	}
+	for _, arquivo := range arquivos {
+		t.Run(filepath.Base(arquivo), func(t *testing.T) {
+			esperado, err := os.ReadFile(arquivo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			g, err := LerMapaTexto(bytes.NewReader(esperado))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var saida bytes.Buffer
+			if err := g.EscreverMapaTexto(&saida); err != nil {
+				t.Fatal(err)
+			}
+			if saida.String() != string(esperado) {
+				t.Errorf("mapa reescrito difere de %s:\n%s", arquivo, saida.String())
+			}
+		})
+	}
+}
+
+func TestMapaTextoJogoPadrao(t *testing.T) {
+	arquivo := filepath.Join("testdata", "padrao.txt")
+	g := NovoJogo()
+	var saida bytes.Buffer
+	if err := g.EscreverMapaTexto(&saida); err != nil {
+		t.Fatal(err)
+	}
+	if *atualizar {
+		if err := os.WriteFile(arquivo, saida.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	esperado, err := os.ReadFile(arquivo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saida.String() != string(esperado) {
+		t.Fatalf("NovoJogo exportado difere de %s (rode com -atualizar)", arquivo)
+	}
+
+	lido, err := LerMapaTexto(&saida)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(lido, g) {
+		t.Error("o jogo lido do texto difere de NovoJogo")
+	}
+}
+
+func TestMapaTextoPersonalizado(t *testing.T) {
+	arquivo, err := os.Open(filepath.Join("testdata", "lava.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer arquivo.Close()
+	g, err := LerMapaTexto(arquivo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lava := indiceTerreno(g.Terrenos, "lava")
+	if lava < 0 || !g.Terrenos[lava].Intransponivel {
+		t.Fatalf("terrenos = %+v", g.Terrenos)
+	}
+	if casa := g.Casas[1].Posicao; g.Mapa[casa.X][casa.Y] != ROCHOSO {
+		t.Errorf("terreno sob a casa b = %d, esperado rochoso", g.Mapa[casa.X][casa.Y])
+	}
+	if g.Cavaleiros[0].Nome != "Seiya de Pégaso" {
+		t.Errorf("cavaleiro = %q", g.Cavaleiros[0].Nome)
+	}
+	for _, p := range g.Buscar(OpcoesBusca{Algoritmo: ALGORITMO_HELD_KARP}).Caminho {
+		if p := g.Mapa[p.X][p.Y]; p == lava {
+			t.Fatal("o caminho atravessa lava")
+		}
+	}
+}
+
+func TestMapaTextoErros(t *testing.T) {
+	casos := []struct {
+		nome, texto, campo string
+	}{
+		{"sem mapa", "cavaleiro 1 5 Seiya\n", "mapa"},
+		{"diretiva", "teleporte a b\nmapa\nE.G\n...\n...\n", "linha 1"},
+		{"símbolo reservado", "terreno E 1 - entrada\nmapa\nE.G\n...\n...\n", "linha 1"},
+		{"colunas", "mapa\nE.G\n..\n...\n", "linha 3"},
+		{"símbolo desconhecido", "mapa\nE.G\n.?.\n...\n", "linha 3"},
+		{"sem grande mestre", "mapa\nE..\n...\n...\n", "mapa"},
+		{"casa fora de ordem", "mapa\nE.G\n.b.\n...\n", "mapa"},
+		{"inalcançável", "terreno # x - parede\nterreno . 1 - plano\nmapa\nE#.\n##.\n..G\n", "grande_mestre"},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nome, func(t *testing.T) {
+			_, err := LerMapaTexto(strings.NewReader(caso.texto))
+			var erros ErrosValidacao
+			if !errors.As(err, &erros) {
+				t.Fatalf("erro = %v, esperado ErrosValidacao", err)
+			}
+			if erros[0].Campo != caso.campo {
+				t.Errorf("erros = %v, esperado campo %q", erros, caso.campo)
+			}
+		})
+	}
+}
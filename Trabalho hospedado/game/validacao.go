@@ -0,0 +1,192 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ---------------- Validação ----------------
+type ErroValidacao struct {
+	Campo    string `json:"campo"`
+	Mensagem string `json:"mensagem"`
+}
+
+type ErrosValidacao []ErroValidacao
+
+func (e ErrosValidacao) Error() string {
+	mensagens := make([]string, len(e))
+	for i, erro := range e {
+		mensagens[i] = erro.Campo + ": " + erro.Mensagem
+	}
+	return strings.Join(mensagens, "; ")
+}
+
+func (e *ErrosValidacao) adicionar(campo, formato string, args ...interface{}) {
+	*e = append(*e, ErroValidacao{Campo: campo, Mensagem: fmt.Sprintf(formato, args...)})
+}
+
+// Validar reporta de uma vez todos os problemas do cenário, cada um com o
+// caminho do campo JSON responsável. Retorna nil quando o jogo é válido.
+func (g *Game) Validar() error {
+	var erros ErrosValidacao
+
+	if g.Size <= 0 {
+		erros.adicionar("size", "deve ser positivo, recebido %d", g.Size)
+		return erros
+	}
+	if g.Size > TAMANHO_MAXIMO_MAPA {
+		erros.adicionar("size", "deve ser no máximo %d, recebido %d", TAMANHO_MAXIMO_MAPA, g.Size)
+		return erros
+	}
+
+	mapaValido := len(g.Mapa) == g.Size
+	if !mapaValido {
+		erros.adicionar("mapa", "esperadas %d linhas, recebidas %d", g.Size, len(g.Mapa))
+	}
+	for i, linha := range g.Mapa {
+		if len(linha) != g.Size {
+			erros.adicionar(fmt.Sprintf("mapa[%d]", i), "esperadas %d colunas, recebidas %d", g.Size, len(linha))
+			mapaValido = false
+		}
+	}
+
+	if !g.posicaoValida(g.Entrada) {
+		erros.adicionar("entrada", "posição (%d, %d) fora do mapa %dx%d", g.Entrada.X, g.Entrada.Y, g.Size, g.Size)
+	}
+	if !g.posicaoValida(g.GrandeMestre) {
+		erros.adicionar("grande_mestre", "posição (%d, %d) fora do mapa %dx%d", g.GrandeMestre.X, g.GrandeMestre.Y, g.Size, g.Size)
+	}
+	if g.Entrada == g.GrandeMestre {
+		erros.adicionar("grande_mestre", "coincide com a entrada")
+	}
+
+	g.Movimento.validar("movimento", &erros)
+
+	if len(g.Cavaleiros) == 0 {
+		erros.adicionar("cavaleiros", "nenhum cavaleiro informado")
+	}
+	for i, cavaleiro := range g.Cavaleiros {
+		if cavaleiro.PoderCosmico <= 0 {
+			erros.adicionar(fmt.Sprintf("cavaleiros[%d].poder_cosmico", i), "deve ser positivo, recebido %g", cavaleiro.PoderCosmico)
+		}
+		if cavaleiro.Energia < 0 {
+			erros.adicionar(fmt.Sprintf("cavaleiros[%d].energia", i), "não pode ser negativa, recebido %d", cavaleiro.Energia)
+		}
+	}
+
+	if len(g.Casas) > MAXIMO_CASAS {
+		erros.adicionar("casas", "no máximo %d casas, recebidas %d", MAXIMO_CASAS, len(g.Casas))
+	}
+
+	posicoes := make(map[Point]int)
+	for i, casa := range g.Casas {
+		campo := fmt.Sprintf("casas[%d].posicao", i)
+		switch {
+		case !g.posicaoValida(casa.Posicao):
+			erros.adicionar(campo, "posição (%d, %d) fora do mapa %dx%d", casa.Posicao.X, casa.Posicao.Y, g.Size, g.Size)
+			continue
+		case casa.Posicao == g.Entrada:
+			erros.adicionar(campo, "coincide com a entrada")
+		case casa.Posicao == g.GrandeMestre:
+			erros.adicionar(campo, "coincide com o grande mestre")
+		}
+
+		if anterior, existe := posicoes[casa.Posicao]; existe {
+			erros.adicionar(campo, "mesma posição da casa %s", g.Casas[anterior].Nome)
+			continue
+		}
+		posicoes[casa.Posicao] = i
+	}
+
+	if !mapaValido {
+		return erros
+	}
+
+	if len(g.Terrenos) == 0 {
+		erros.adicionar("terrenos", "nenhum terreno informado")
+	}
+	validarTerrenos(g.Terrenos, "terrenos", &erros)
+	for x, linha := range g.Mapa {
+		for y, terreno := range linha {
+			if terreno < 0 || terreno >= len(g.Terrenos) {
+				erros.adicionar(fmt.Sprintf("mapa[%d][%d]", x, y), "terreno %d desconhecido", terreno)
+			}
+		}
+	}
+	if len(erros) > 0 {
+		return erros
+	}
+
+	// Nenhum marco pode ficar sobre terreno intransponível
+	marcos := []Point{g.Entrada, g.GrandeMestre}
+	campos := []string{"entrada", "grande_mestre"}
+	for i, casa := range g.Casas {
+		marcos = append(marcos, casa.Posicao)
+		campos = append(campos, fmt.Sprintf("casas[%d].posicao", i))
+	}
+	for i, p := range marcos {
+		if !g.transitavel(p) {
+			erros.adicionar(campos[i], "sobre terreno intransponível %s", g.Terrenos[g.Mapa[p.X][p.Y]].Nome)
+		}
+	}
+
+	if len(erros) > 0 {
+		return erros
+	}
+
+	// Com o jogo bem formado, a análise de conectividade aponta os marcos
+	// inalcançáveis
+	return g.Analisar().erro()
+}
+
+// Erros de decodificação viram erros de validação apontando o campo
+func erroDecodificacao(err error) error {
+	var erroTipo *json.UnmarshalTypeError
+	var erroSintaxe *json.SyntaxError
+
+	switch {
+	case errors.As(err, &erroTipo):
+		return ErrosValidacao{{
+			Campo:    campoJSON(erroTipo.Field),
+			Mensagem: fmt.Sprintf("esperado %s, recebido %s", erroTipo.Type, erroTipo.Value),
+		}}
+	case errors.As(err, &erroSintaxe):
+		return ErrosValidacao{{Mensagem: fmt.Sprintf("JSON inválido na posição %d: %v", erroSintaxe.Offset, err)}}
+	}
+	return ErrosValidacao{{Mensagem: fmt.Sprintf("JSON inválido: %v", err)}}
+}
+
+// encoding/json descreve índices como "casas.3.posicao"; usamos "casas[3].posicao"
+func campoJSON(campo string) string {
+	partes := strings.Split(campo, ".")
+	var b strings.Builder
+	for i, parte := range partes {
+		if _, err := strconv.Atoi(parte); err == nil && i > 0 {
+			b.WriteString("[" + parte + "]")
+			continue
+		}
+		if i > 0 {
+			b.WriteString(".")
+		}
+		b.WriteString(parte)
+	}
+	return b.String()
+}
+
+// ---------------- Respostas de erro ----------------
+func ResponderErro(w http.ResponseWriter, err error) {
+	var erros ErrosValidacao
+	status := http.StatusUnprocessableEntity
+	if !errors.As(err, &erros) {
+		status = http.StatusBadRequest
+		erros = ErrosValidacao{{Mensagem: err.Error()}}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"erros": erros})
+}
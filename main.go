@@ -2,11 +2,19 @@ package main
 
 import (
 	"container/heap"
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"math"
+	"math/rand"
 	"net/http"
+	"os"
+	"sort"
+	"strconv"
 	"time"
 )
 
@@ -26,6 +34,14 @@ var CUSTOS_TERRENO = map[int]int{
 	ROCHOSO:    5,
 }
 
+var NOMES_TERRENO = map[string]int{
+	"montanhoso": MONTANHOSO,
+	"plano":      PLANO,
+	"rochoso":    ROCHOSO,
+}
+
+var arquivoCenario = flag.String("config", "config.json", "arquivo de cenário (vazio para o jogo embutido)")
+
 type CavaleiroBronze struct {
 	Nome         string  `json:"nome"`
 	PoderCosmico float64 `json:"poder_cosmico"`
@@ -78,10 +94,14 @@ type Game struct {
 	Entrada      Point             `json:"entrada"`
 	GrandeMestre Point             `json:"grande_mestre"`
 	Size         int               `json:"size"`
+
+	CustosTerreno map[int]int `json:"custos_terreno,omitempty"`
 }
 
 type ResultadoBusca struct {
 	Sucesso      bool          `json:"sucesso"`
+	Motivo       string        `json:"motivo,omitempty"`
+	Abortada     string        `json:"abortada,omitempty"`
 	Caminho      []Point       `json:"caminho"`
 	CustoTotal   int           `json:"custo_total"`
 	Duracao      string        `json:"duracao"`
@@ -127,23 +147,196 @@ func NovoJogo() *Game {
 	return game
 }
 
+type Cenario struct {
+	Cavaleiros    []CavaleiroBronze    `json:"cavaleiros"`
+	Casas         []CasaZodiaco        `json:"casas_zodiaco"`
+	Configuracoes ConfiguracoesCenario `json:"configuracoes"`
+}
+
+type ConfiguracoesCenario struct {
+	TamanhoMapa   int            `json:"tamanho_mapa"`
+	Entrada       Point          `json:"entrada"`
+	GrandeMestre  Point          `json:"grande_mestre"`
+	CustosTerreno map[string]int `json:"custos_terreno"`
+}
+
+func CarregarCenario(caminho string) (*Game, error) {
+	arquivo, err := os.Open(caminho)
+	if err != nil {
+		return nil, fmt.Errorf("abrindo cenário %s: %w", caminho, err)
+	}
+	defer arquivo.Close()
+
+	g, err := LerCenario(arquivo)
+	if err != nil {
+		return nil, fmt.Errorf("cenário %s: %w", caminho, err)
+	}
+	return g, nil
+}
+
+func LerCenario(r io.Reader) (*Game, error) {
+	var cenario Cenario
+	if err := json.NewDecoder(r).Decode(&cenario); err != nil {
+		return nil, fmt.Errorf("decodificando cenário: %w", err)
+	}
+	return cenario.NovoJogo()
+}
+
+func (c Cenario) NovoJogo() (*Game, error) {
+	if c.Configuracoes.TamanhoMapa <= 0 {
+		return nil, fmt.Errorf("tamanho_mapa deve ser positivo, recebido %d", c.Configuracoes.TamanhoMapa)
+	}
+
+	game := &Game{
+		Size:         c.Configuracoes.TamanhoMapa,
+		Cavaleiros:   c.Cavaleiros,
+		Casas:        c.Casas,
+		Entrada:      c.Configuracoes.Entrada,
+		GrandeMestre: c.Configuracoes.GrandeMestre,
+	}
+
+	// Os custos do cenário substituem CUSTOS_TERRENO apenas para este jogo
+	if c.Configuracoes.CustosTerreno != nil {
+		game.CustosTerreno = make(map[int]int, len(c.Configuracoes.CustosTerreno))
+		for nome, custo := range c.Configuracoes.CustosTerreno {
+			terreno, existe := NOMES_TERRENO[nome]
+			if !existe {
+				return nil, fmt.Errorf("terreno desconhecido em custos_terreno: %q", nome)
+			}
+			game.CustosTerreno[terreno] = custo
+		}
+	}
+
+	game.inicializarMapa()
+	return game, nil
+}
+
+// lerJogo decodifica um cenário no formato do Game enviado pelo cliente
+// Limites dos cenários enviados por POST
+const (
+	TAMANHO_MAXIMO_CORPO = 8 << 20
+	TAMANHO_MAXIMO_MAPA  = 256
+)
+
+func lerJogo(r io.Reader) (*Game, error) {
+	var game Game
+	if err := json.NewDecoder(r).Decode(&game); err != nil {
+		return nil, fmt.Errorf("decodificando cenário: %w", err)
+	}
+
+	if game.Size <= 0 {
+		return nil, fmt.Errorf("size deve ser positivo, recebido %d", game.Size)
+	}
+	if game.Size > TAMANHO_MAXIMO_MAPA {
+		return nil, fmt.Errorf("size deve ser no máximo %d, recebido %d", TAMANHO_MAXIMO_MAPA, game.Size)
+	}
+	if len(game.Mapa) == 0 {
+		game.inicializarMapa()
+	}
+
+	if len(game.Mapa) != game.Size {
+		return nil, fmt.Errorf("mapa deve ter %d linhas, recebidas %d", game.Size, len(game.Mapa))
+	}
+	for i, linha := range game.Mapa {
+		if len(linha) != game.Size {
+			return nil, fmt.Errorf("mapa[%d] deve ter %d colunas, recebidas %d", i, game.Size, len(linha))
+		}
+	}
+	if !game.posicaoValida(game.Entrada) || !game.posicaoValida(game.GrandeMestre) {
+		return nil, fmt.Errorf("entrada e grande_mestre devem estar dentro do mapa")
+	}
+	return &game, nil
+}
+
+func carregarJogo() *Game {
+	if *arquivoCenario == "" {
+		return NovoJogo()
+	}
+
+	game, err := CarregarCenario(*arquivoCenario)
+	if err != nil {
+		log.Printf("usando cenário padrão: %v", err)
+		return NovoJogo()
+	}
+	return game
+}
+
+// Gerador do terreno, o mesmo do pacote game hospedado: um ruído de valor
+// espalha as montanhas e passeios aleatórios puxados para o destino abrem
+// corredores de PLANO e ROCHOSO entre os marcos, na ordem, de modo que todos
+// são alcançáveis sem pisar em montanha. A mesma semente dá sempre o mesmo
+// mapa; /api/game?seed= gera outro.
+const (
+	SEMENTE_PADRAO     = 42
+	DENSIDADE_PADRAO   = 0.6
+	SINUOSIDADE_PADRAO = 0.35
+)
+
 func (g *Game) inicializarMapa() {
+	g.gerarMapa(SEMENTE_PADRAO)
+}
+
+func (g *Game) gerarMapa(semente int64) {
+	rng := rand.New(rand.NewSource(semente))
+	terrenoCorredor := func() int {
+		if rng.Float64() < 0.3 {
+			return ROCHOSO
+		}
+		return PLANO
+	}
+
+	ruido := ruidoValor(rng, g.Size)
+	limiar := quantil(ruido, DENSIDADE_PADRAO)
 	g.Mapa = make([][]int, g.Size)
 	for i := range g.Mapa {
 		g.Mapa[i] = make([]int, g.Size)
 		for j := range g.Mapa[i] {
-			if (i+j)%3 == 0 {
+			if ruido[i][j] <= limiar {
 				g.Mapa[i][j] = MONTANHOSO
-			} else if (i+j)%3 == 1 {
-				g.Mapa[i][j] = PLANO
 			} else {
-				g.Mapa[i][j] = ROCHOSO
+				g.Mapa[i][j] = terrenoCorredor()
 			}
 		}
 	}
 
-	g.Mapa[g.Entrada.X][g.Entrada.Y] = ENTRADA
-	g.Mapa[g.GrandeMestre.X][g.GrandeMestre.Y] = GRANDE_MESTRE
+	// Corredores na ordem zodiacal e alguns atalhos entre marcos quaisquer
+	var rota []Point
+	for _, marco := range append(append([]Point{g.Entrada}, g.posicoesCasas()...), g.GrandeMestre) {
+		if g.posicaoValida(marco) {
+			rota = append(rota, marco)
+		}
+	}
+	if len(rota) > 0 {
+		for i := 1; i < len(rota); i++ {
+			g.abrirCorredor(rng, rota[i-1], rota[i], terrenoCorredor)
+		}
+		for i := 0; i < len(g.Casas)/3; i++ {
+			g.abrirCorredor(rng, rota[rng.Intn(len(rota))], rota[rng.Intn(len(rota))], terrenoCorredor)
+		}
+	}
+
+	// Marcos cercados de terreno transitável
+	for _, marco := range append([]Point{g.Entrada, g.GrandeMestre}, g.posicoesCasas()...) {
+		if !g.posicaoValida(marco) {
+			continue
+		}
+		for dx := -1; dx <= 1; dx++ {
+			for dy := -1; dy <= 1; dy++ {
+				vizinho := Point{marco.X + dx, marco.Y + dy}
+				if g.posicaoValida(vizinho) && g.Mapa[vizinho.X][vizinho.Y] == MONTANHOSO {
+					g.Mapa[vizinho.X][vizinho.Y] = terrenoCorredor()
+				}
+			}
+		}
+		g.Mapa[marco.X][marco.Y] = PLANO
+	}
+
+	if g.posicaoValida(g.Entrada) {
+		g.Mapa[g.Entrada.X][g.Entrada.Y] = ENTRADA
+	}
+	if g.posicaoValida(g.GrandeMestre) {
+		g.Mapa[g.GrandeMestre.X][g.GrandeMestre.Y] = GRANDE_MESTRE
+	}
 
 	for i, casa := range g.Casas {
 		if casa.Posicao.X >= 0 && casa.Posicao.X < g.Size &&
@@ -153,6 +346,109 @@ func (g *Game) inicializarMapa() {
 	}
 }
 
+func (g *Game) posicoesCasas() []Point {
+	posicoes := make([]Point, len(g.Casas))
+	for i, casa := range g.Casas {
+		posicoes[i] = casa.Posicao
+	}
+	return posicoes
+}
+
+// abrirCorredor caminha de origem até destino trocando montanhas por terreno
+// de corredor. A cada passo, com chance SINUOSIDADE_PADRAO, anda numa direção
+// qualquer; senão, anda rumo ao destino.
+func (g *Game) abrirCorredor(rng *rand.Rand, origem, destino Point, terrenoCorredor func() int) {
+	direcoes := [4]Point{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+	// Depois de tantos passos o corredor segue reto, para não vagar sem fim
+	limite := 4 * g.Size * g.Size
+	p := origem
+	for passo := 0; p != destino; passo++ {
+		var d Point
+		if passo < limite && rng.Float64() < SINUOSIDADE_PADRAO {
+			d = direcoes[rng.Intn(len(direcoes))]
+		} else {
+			dx, dy := destino.X-p.X, destino.Y-p.Y
+			// Escolhe o eixo na proporção da distância que falta em cada um
+			if rng.Intn(abs(dx)+abs(dy)) < abs(dx) {
+				d = Point{sinal(dx), 0}
+			} else {
+				d = Point{0, sinal(dy)}
+			}
+		}
+		proximo := Point{p.X + d.X, p.Y + d.Y}
+		if !g.posicaoValida(proximo) {
+			continue
+		}
+		p = proximo
+		if g.Mapa[p.X][p.Y] == MONTANHOSO {
+			g.Mapa[p.X][p.Y] = terrenoCorredor()
+		}
+	}
+}
+
+// ruidoValor soma duas oitavas de ruído de valor com interpolação suave.
+func ruidoValor(rng *rand.Rand, tamanho int) [][]float64 {
+	ruido := make([][]float64, tamanho)
+	for x := range ruido {
+		ruido[x] = make([]float64, tamanho)
+	}
+	for _, oitava := range []struct {
+		celula int
+		peso   float64
+	}{{8, 1}, {3, 0.4}} {
+		pontos := tamanho/oitava.celula + 2
+		grade := make([][]float64, pontos)
+		for i := range grade {
+			grade[i] = make([]float64, pontos)
+			for j := range grade[i] {
+				grade[i][j] = rng.Float64()
+			}
+		}
+		for x := 0; x < tamanho; x++ {
+			for y := 0; y < tamanho; y++ {
+				gx, gy := float64(x)/float64(oitava.celula), float64(y)/float64(oitava.celula)
+				i, j := int(gx), int(gy)
+				tx, ty := suavizar(gx-float64(i)), suavizar(gy-float64(j))
+				topo := grade[i][j]*(1-ty) + grade[i][j+1]*ty
+				base := grade[i+1][j]*(1-ty) + grade[i+1][j+1]*ty
+				ruido[x][y] += oitava.peso * (topo*(1-tx) + base*tx)
+			}
+		}
+	}
+	return ruido
+}
+
+func suavizar(t float64) float64 {
+	return t * t * (3 - 2*t)
+}
+
+// quantil devolve o valor abaixo do qual fica a fração dada do ruído.
+func quantil(ruido [][]float64, fracao float64) float64 {
+	valores := make([]float64, 0, len(ruido)*len(ruido))
+	for _, linha := range ruido {
+		valores = append(valores, linha...)
+	}
+	sort.Float64s(valores)
+	return valores[int(fracao*float64(len(valores)-1))]
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func sinal(n int) int {
+	switch {
+	case n > 0:
+		return 1
+	case n < 0:
+		return -1
+	}
+	return 0
+}
+
 func distanciaManhattan(a, b Point) int {
 	return int(math.Abs(float64(a.X-b.X)) + math.Abs(float64(a.Y-b.Y)))
 }
@@ -178,17 +474,25 @@ func (g *Game) obterVizinhos(p Point) []Point {
 	return validosResult
 }
 
+func (g *Game) custos() map[int]int {
+	if g.CustosTerreno != nil {
+		return g.CustosTerreno
+	}
+	return CUSTOS_TERRENO
+}
+
 func (g *Game) custoMovimento(p Point) int {
 	terreno := g.Mapa[p.X][p.Y]
+	custos := g.custos()
 
 	if terreno >= CASA_ZODIACO {
-		return CUSTOS_TERRENO[PLANO]
+		return custos[PLANO]
 	}
 
-	if custo, existe := CUSTOS_TERRENO[terreno]; existe {
+	if custo, existe := custos[terreno]; existe {
 		return custo
 	}
-	return CUSTOS_TERRENO[PLANO]
+	return custos[PLANO]
 }
 
 func (g *Game) tempoBatalha(casaID int, cavaleirosParticipantes []int) float64 {
@@ -221,8 +525,42 @@ func todasCasasVisitadas(visited []bool) bool {
 	return true
 }
 
-func (g *Game) AStar() ResultadoBusca {
+func contarVisitadas(visited []bool) int {
+	total := 0
+	for _, v := range visited {
+		if v {
+			total++
+		}
+	}
+	return total
+}
+
+// Motivos de interrupção em ResultadoBusca.Abortada
+const (
+	ABORTADA_CANCELADA    = "cancelada"
+	ABORTADA_LIMITE_NOS   = "limite_nos"
+	ABORTADA_LIMITE_TEMPO = "limite_tempo"
+
+	// De quantos em quantos nós o contexto é consultado
+	INTERVALO_VERIFICACAO = 256
+)
+
+// OpcoesBusca traz os orçamentos opcionais da busca (zero = sem limite)
+type OpcoesBusca struct {
+	MaximoNos   int
+	LimiteTempo time.Duration
+}
+
+// AStar para quando ctx é cancelado (ex.: o cliente desconectou) ou um
+// orçamento estoura, e devolve o caminho até o nó com mais casas conquistadas
+// e menor H expandido até ali.
+func (g *Game) AStar(ctx context.Context, opcoes OpcoesBusca) ResultadoBusca {
 	inicio := time.Now()
+	if opcoes.LimiteTempo > 0 {
+		var cancelar context.CancelFunc
+		ctx, cancelar = context.WithTimeout(ctx, opcoes.LimiteTempo)
+		defer cancelar()
+	}
 
 	openSet := &PriorityQueue{}
 	heap.Init(openSet)
@@ -240,8 +578,24 @@ func (g *Game) AStar() ResultadoBusca {
 
 	heap.Push(openSet, inicial)
 	visited := make(map[string]*Node)
+	var melhor *Node
+	expandidos := 0
 
 	for openSet.Len() > 0 {
+		if motivo := esgotado(ctx, opcoes, expandidos); motivo != "" {
+			var caminho []Point
+			for no := melhor; no != nil; no = no.Parent {
+				caminho = append([]Point{no.Point}, caminho...)
+			}
+			return ResultadoBusca{
+				Sucesso:  false,
+				Motivo:   descreverInterrupcao(motivo, expandidos),
+				Abortada: motivo,
+				Caminho:  caminho,
+				Duracao:  time.Since(inicio).String(),
+			}
+		}
+
 		atual := heap.Pop(openSet).(*Node)
 
 		chave := fmt.Sprintf("%d,%d,%v", atual.X, atual.Y, atual.Visited)
@@ -252,6 +606,11 @@ func (g *Game) AStar() ResultadoBusca {
 			}
 		}
 		visited[chave] = atual
+		expandidos++
+		if melhor == nil || contarVisitadas(atual.Visited) > contarVisitadas(melhor.Visited) ||
+			(contarVisitadas(atual.Visited) == contarVisitadas(melhor.Visited) && atual.H < melhor.H) {
+			melhor = atual
+		}
 
 		if atual.Point == g.GrandeMestre && todasCasasVisitadas(atual.Visited) {
 			var caminho []Point
@@ -337,6 +696,60 @@ func (g *Game) AStar() ResultadoBusca {
 	}
 }
 
+// esgotado devolve o motivo da interrupção (ABORTADA_*) ou "" para seguir.
+func esgotado(ctx context.Context, opcoes OpcoesBusca, expandidos int) string {
+	if opcoes.MaximoNos > 0 && expandidos >= opcoes.MaximoNos {
+		return ABORTADA_LIMITE_NOS
+	}
+	if expandidos%INTERVALO_VERIFICACAO != 0 {
+		return ""
+	}
+	switch err := ctx.Err(); {
+	case errors.Is(err, context.DeadlineExceeded):
+		return ABORTADA_LIMITE_TEMPO
+	case err != nil:
+		return ABORTADA_CANCELADA
+	}
+	return ""
+}
+
+func descreverInterrupcao(motivo string, expandidos int) string {
+	switch motivo {
+	case ABORTADA_LIMITE_NOS:
+		return fmt.Sprintf("busca abortada: limite de %d nós expandidos atingido", expandidos)
+	case ABORTADA_LIMITE_TEMPO:
+		return fmt.Sprintf("busca abortada: limite de tempo atingido após %d nós expandidos", expandidos)
+	}
+	return fmt.Sprintf("busca abortada: cancelada após %d nós expandidos", expandidos)
+}
+
+// opcoesDaRequisicao lê max_nos e limite_ms, como no servidor hospedado
+func opcoesDaRequisicao(r *http.Request) (OpcoesBusca, error) {
+	var opcoes OpcoesBusca
+	query := r.URL.Query()
+	if texto := query.Get("max_nos"); texto != "" {
+		valor, err := strconv.Atoi(texto)
+		if err != nil || valor < 0 {
+			return opcoes, fmt.Errorf("max_nos inválido: %q", texto)
+		}
+		opcoes.MaximoNos = valor
+	}
+	if texto := query.Get("limite_ms"); texto != "" {
+		valor, err := strconv.Atoi(texto)
+		if err != nil || valor < 0 {
+			return opcoes, fmt.Errorf("limite_ms inválido: %q", texto)
+		}
+		opcoes.LimiteTempo = time.Duration(valor) * time.Millisecond
+	}
+	return opcoes, nil
+}
+
+func responderErro(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{"erro": err.Error()})
+}
+
 // Handlers HTTP
 func enableCORS(w http.ResponseWriter) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -350,7 +763,15 @@ func getGameState(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	game := NovoJogo()
+	game := carregarJogo()
+	if texto := r.URL.Query().Get("seed"); texto != "" {
+		semente, err := strconv.ParseInt(texto, 10, 64)
+		if err != nil {
+			responderErro(w, fmt.Errorf("seed inválida: %q", texto))
+			return
+		}
+		game.gerarMapa(semente)
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(game)
 }
@@ -361,8 +782,20 @@ func executarBusca(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	game := NovoJogo()
-	resultado := game.AStar()
+	opcoes, err := opcoesDaRequisicao(r)
+	if err != nil {
+		responderErro(w, err)
+		return
+	}
+	game := carregarJogo()
+	if r.Method == http.MethodPost {
+		if game, err = lerJogo(http.MaxBytesReader(w, r.Body, TAMANHO_MAXIMO_CORPO)); err != nil {
+			responderErro(w, err)
+			return
+		}
+	}
+
+	resultado := game.AStar(r.Context(), opcoes)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resultado)
@@ -377,6 +810,8 @@ func serveStatic(w http.ResponseWriter, r *http.Request) {
 }
 
 func main() {
+	flag.Parse()
+
 	fmt.Println("🌟 Servidor Cavaleiros do Zodíaco iniciando...")
 	fmt.Println("🌐 Acesse: http://localhost:8081")
 